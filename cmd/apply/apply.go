@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/chadlyb/qadam/patch"
+)
+
+// backupDirName is where apply keeps the original files, inside the game directory
+const backupDirName = "qadam-backup"
+
+// change is a file of the game that a bundle changes
+type change struct {
+	entry  patch.FileEntry
+	path   string // in the game directory
+	target []byte
+}
+
+// apply applies the patch bundle in bundleDir to the game in gameDir. Every file is
+// checked and patched in memory before any is written; the originals are copied to
+// the backup directory first, and put back if writing fails.
+func apply(bundleDir, gameDir string) error {
+	m, err := patch.ReadManifest(bundleDir)
+	if err != nil {
+		return err
+	}
+
+	var changes []change
+	for _, e := range m.Files {
+		if !filepath.IsLocal(filepath.FromSlash(e.Path)) {
+			return fmt.Errorf("manifest names a file outside the game directory: '%v'", e.Path)
+		}
+		path := filepath.Join(gameDir, filepath.FromSlash(e.Path))
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("couldn't read %v from the game: %w", e.Path, err)
+		}
+		if patch.Hash(source) == e.TargetSHA256 {
+			fmt.Printf("INFO: %v is already translated\n", e.Path)
+			continue
+		}
+		target, err := e.Apply(bundleDir, source)
+		if err != nil {
+			return fmt.Errorf("%w; is this the edition of the game the translation is for?", err)
+		}
+		changes = append(changes, change{e, path, target})
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	backupDir := filepath.Join(gameDir, backupDirName)
+	for _, c := range changes {
+		if err := backup(c.path, filepath.Join(backupDir, filepath.FromSlash(c.entry.Path))); err != nil {
+			return err
+		}
+	}
+
+	for i, c := range changes {
+		if err := os.WriteFile(c.path, c.target, 0644); err != nil {
+			err = fmt.Errorf("couldn't write %v: %w", c.entry.Path, err)
+			if restoreErr := restore(gameDir, changes[:i+1]); restoreErr != nil {
+				return fmt.Errorf("%w; and putting back the originals failed: %w", err, restoreErr)
+			}
+			return fmt.Errorf("%w; the originals were put back", err)
+		}
+		fmt.Printf("INFO: Translated %v\n", c.entry.Path)
+	}
+	return nil
+}
+
+// backup copies the file at path to backupPath, unless an earlier backup is already there
+func backup(path, backupPath string) error {
+	if _, err := os.Stat(backupPath); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("couldn't read '%v' to back it up: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return fmt.Errorf("couldn't create backup directory: %w", err)
+	}
+	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+		return fmt.Errorf("couldn't back up '%v': %w", path, err)
+	}
+	return nil
+}
+
+// restore puts back the backed-up originals of changes
+func restore(gameDir string, changes []change) error {
+	var errs []error
+	for _, c := range changes {
+		data, err := os.ReadFile(filepath.Join(gameDir, backupDirName, filepath.FromSlash(c.entry.Path)))
+		if err == nil {
+			err = os.WriteFile(c.path, data, 0644)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", c.entry.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollback puts back every original file in the game's backup directory, then removes it
+func rollback(gameDir string) error {
+	backupDir := filepath.Join(gameDir, backupDirName)
+	if _, err := os.Stat(backupDir); err != nil {
+		return fmt.Errorf("no backup to restore in '%v'", gameDir)
+	}
+
+	err := filepath.WalkDir(backupDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(backupDir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(gameDir, rel), data, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("INFO: Restored %v\n", filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't restore the originals: %w", err)
+	}
+	return os.RemoveAll(backupDir)
+}
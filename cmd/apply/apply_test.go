@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/patch"
+)
+
+// setup writes an original game and a translated copy, and makes a bundle from them.
+// It returns the bundle and game directories.
+func setup(t *testing.T) (string, string) {
+	dir := t.TempDir()
+	files := map[string][2]string{
+		"GAME.EXE":  {"MZ Hello\x00Goodbye\x00", "MZ Ahoj\x00Nashledanou\x00"},
+		"TEXTS.FIL": {"texts", "texty"},
+		"OTHER.DAT": {"same", "same"},
+	}
+	for name, contents := range files {
+		for i, sub := range []string{"game", "built"} {
+			path := filepath.Join(dir, sub, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(contents[i]), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := patch.WriteBundle(filepath.Join(dir, "game"), filepath.Join(dir, "built"), filepath.Join(dir, "bundle")); err != nil {
+		t.Fatalf("WriteBundle failed: %v", err)
+	}
+	return filepath.Join(dir, "bundle"), filepath.Join(dir, "game")
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %v: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestApplyAndRollback(t *testing.T) {
+	bundleDir, gameDir := setup(t)
+
+	if err := apply(bundleDir, gameDir); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(gameDir, "GAME.EXE")); got != "MZ Ahoj\x00Nashledanou\x00" {
+		t.Errorf("Expected GAME.EXE to be translated, got %q", got)
+	}
+	if got := readFile(t, filepath.Join(gameDir, backupDirName, "GAME.EXE")); got != "MZ Hello\x00Goodbye\x00" {
+		t.Errorf("Expected the original GAME.EXE to be backed up, got %q", got)
+	}
+
+	// Applying again finds it's done
+	if err := apply(bundleDir, gameDir); err != nil {
+		t.Errorf("Expected applying twice to succeed, got %v", err)
+	}
+
+	if err := rollback(gameDir); err != nil {
+		t.Fatalf("rollback failed: %v", err)
+	}
+	if got := readFile(t, filepath.Join(gameDir, "TEXTS.FIL")); got != "texts" {
+		t.Errorf("Expected TEXTS.FIL to be restored, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(gameDir, backupDirName)); !os.IsNotExist(err) {
+		t.Errorf("Expected the backup to be removed, got %v", err)
+	}
+}
+
+func TestApplyWrongGame(t *testing.T) {
+	bundleDir, gameDir := setup(t)
+	if err := os.WriteFile(filepath.Join(gameDir, "TEXTS.FIL"), []byte("other edition"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := apply(bundleDir, gameDir)
+	if err == nil || !strings.Contains(err.Error(), "TEXTS.FIL") {
+		t.Errorf("Expected an error about TEXTS.FIL, got %v", err)
+	}
+	// Nothing is written unless every file can be patched
+	if got := readFile(t, filepath.Join(gameDir, "GAME.EXE")); got != "MZ Hello\x00Goodbye\x00" {
+		t.Errorf("Expected GAME.EXE to be untouched, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(gameDir, backupDirName)); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup, got %v", err)
+	}
+}
+
+func TestBundleAndGame(t *testing.T) {
+	bundleDir, gameDir := setup(t)
+	for _, args := range [][]string{{bundleDir, gameDir}, {gameDir, bundleDir}} {
+		b, g, err := bundleAndGame(args)
+		if err != nil || b != bundleDir || g != gameDir {
+			t.Errorf("Expected bundle %v and game %v from %v, got %v, %v, %v", bundleDir, gameDir, args, b, g, err)
+		}
+	}
+}
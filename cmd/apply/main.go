@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chadlyb/qadam/patch"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// Version will be set by the linker during build
+var version = "dev"
+
+// isBundle reports whether dir holds a patch bundle
+func isBundle(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, patch.ManifestName))
+	return err == nil
+}
+
+// bundleAndGame works out which of args is the patch bundle and which the game.
+// Folders dragged onto the program come in any order, and if only the game is
+// given, the bundle is taken to be where this program is, as it's shipped with one.
+func bundleAndGame(args []string) (string, string, error) {
+	switch len(args) {
+	case 1:
+		exe, err := os.Executable()
+		if err != nil {
+			return "", "", fmt.Errorf("couldn't find the patch bundle: %w", err)
+		}
+		bundleDir := filepath.Dir(exe)
+		if !isBundle(bundleDir) {
+			return "", "", fmt.Errorf("no %v next to this program; give the patch folder as well as the game folder", patch.ManifestName)
+		}
+		return bundleDir, args[0], nil
+	case 2:
+		if isBundle(args[1]) && !isBundle(args[0]) {
+			return args[1], args[0], nil
+		}
+		return args[0], args[1], nil
+	default:
+		return "", "", fmt.Errorf("expected a patch folder and a game folder, got %v arguments", len(args))
+	}
+}
+
+func main() {
+	showVersion := flag.Bool("version", false, "Show version information")
+	rollbackGame := flag.Bool("rollback", false, "Put back the original files of the game, undoing the translation")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Printf("QADAM Apply Tool v%s\n", version)
+		os.Exit(0)
+	}
+
+	// Get remaining arguments after flag parsing
+	args := flag.Args()
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %v <patch directory> <game directory>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %v <game directory>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %v -rollback <game directory>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %v -version\n", os.Args[0])
+		shared.PauseIfNeeded("Drag the game folder onto this program to translate it.")
+		os.Exit(1)
+	}
+
+	var err error
+	if *rollbackGame {
+		err = rollback(args[0])
+	} else {
+		var bundleDir, gameDir string
+		bundleDir, gameDir, err = bundleAndGame(args)
+		if err == nil {
+			err = apply(bundleDir, gameDir)
+		}
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if *rollbackGame {
+			shared.PauseIfNeeded("Rollback failed! Press Enter to continue...")
+		} else {
+			shared.PauseIfNeeded("Applying the translation failed! Press Enter to continue...")
+		}
+		os.Exit(1)
+	}
+
+	// Pause if running from Explorer so the window doesn't close immediately
+	if *rollbackGame {
+		shared.PauseIfNeeded("The original game files are back! Press Enter to continue...")
+	} else {
+		shared.PauseIfNeeded(fmt.Sprintf("Translation installed! The original files are in %v. Press Enter to continue...", backupDirName))
+	}
+}
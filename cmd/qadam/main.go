@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+
+	"github.com/chadlyb/qadam/internal/qadam"
+)
+
+// Version will be set by the linker during build
+var version = "dev"
+
+func main() {
+	os.Exit(qadam.Run(version, os.Args[1:]))
+}
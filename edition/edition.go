@@ -0,0 +1,139 @@
+// Package edition tells releases of Mise Quadam apart by the hashes of their
+// files, and holds what differs between them: where things are in GAME.EXE,
+// and how the data files are laid out.
+package edition
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files are the files of a release that are fingerprinted, as named in the game directory.
+var Files = []string{"GAME.EXE", "INSTALL.EXE", "TEXTS.FIL", "RESOURCE.FIL"}
+
+// Fingerprint is the SHA-256 of each of Files, in hex, by name. A file that
+// isn't there is absent, and matches no edition.
+type Fingerprint map[string]string
+
+// Range is a range of file offsets, [Begin, End).
+type Range struct {
+	Begin, End int
+}
+
+// Edition is a known release of the game.
+type Edition struct {
+	Name        string
+	Fingerprint Fingerprint
+
+	// SizeFields are the file offsets in GAME.EXE of the 32-bit sizes of the data files, by name.
+	SizeFields map[string]int
+	// StringRanges are where extract looks for text in each executable, by name.
+	StringRanges map[string]Range
+	// Sections are the section counts of the data files, by name.
+	Sections map[string]int
+}
+
+// Known lists the releases whose files have been fingerprinted. None have been
+// yet, so the tools find everything edition-specific themselves.
+//
+// To add one, extract it: the fingerprint of files that aren't known is printed,
+// ready to paste here. Add the constants that were checked against that release.
+var Known = []Edition{}
+
+// FingerprintDir hashes Files in dir. Files that aren't there are left out of
+// the fingerprint, since a game can be missing some, e.g. INSTALL.EXE.
+func FingerprintDir(dir string) (Fingerprint, error) {
+	fp := Fingerprint{}
+	for _, name := range Files {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't fingerprint %v: %w", name, err)
+		}
+		sum := sha256.Sum256(data)
+		fp[name] = hex.EncodeToString(sum[:])
+	}
+	return fp, nil
+}
+
+// String formats the fingerprint as a Go literal, for adding to Known. Absent files are left out.
+func (fp Fingerprint) String() string {
+	var b strings.Builder
+	b.WriteString("Fingerprint{\n")
+	for _, name := range Files {
+		if h, ok := fp[name]; ok {
+			fmt.Fprintf(&b, "\t%q: %q,\n", name, h)
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// Identify finds the edition fp is from. If only some of the files match, it
+// returns the edition most of them match, and the names of those that don't;
+// that happens when some files were already modified. It returns nil if no
+// file matches a known edition.
+func Identify(fp Fingerprint) (*Edition, []string) {
+	var best *Edition
+	var bestDiffers []string
+	bestMatches := 0
+	for i := range Known {
+		e := &Known[i]
+		matches := 0
+		var differs []string
+		for _, name := range Files {
+			if h, ok := fp[name]; ok && h == e.Fingerprint[name] {
+				matches++
+			} else {
+				differs = append(differs, name)
+			}
+		}
+		if matches > bestMatches {
+			best, bestDiffers, bestMatches = e, differs, matches
+		}
+	}
+	return best, bestDiffers
+}
+
+// Describe says which edition fp is from, for printing, and whether it's fully known.
+func Describe(fp Fingerprint) (string, bool) {
+	e, differs := Identify(fp)
+	switch {
+	case e == nil:
+		return "unknown edition", false
+	case len(differs) > 0:
+		return fmt.Sprintf("%v, but %v differ(s) from it", e.Name, strings.Join(differs, ", ")), false
+	default:
+		return e.Name, true
+	}
+}
+
+// Check identifies the edition of the files in dir, printing it to w. Files that
+// aren't a known edition get a warning and their fingerprint, or an error if
+// requireKnown is set. It returns the edition only if every file matches it.
+func Check(dir string, requireKnown bool, w io.Writer) (*Edition, error) {
+	fp, err := FingerprintDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	desc, known := Describe(fp)
+	fmt.Fprintf(w, "INFO: Edition: %v\n", desc)
+	if known {
+		e, _ := Identify(fp)
+		return e, nil
+	}
+	if requireKnown {
+		return nil, fmt.Errorf("files in %v aren't a known edition (%v)", dir, desc)
+	}
+	fmt.Fprintf(w, "WARNING: Files aren't a known edition; edition-specific checks are skipped. Their fingerprint is:\n%v\n", fp)
+	return nil, nil
+}
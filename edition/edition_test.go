@@ -0,0 +1,93 @@
+package edition
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRelease writes Files to a new directory, each holding its own name
+func writeRelease(t *testing.T) string {
+	dir := t.TempDir()
+	for _, name := range Files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write %v: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestCheck(t *testing.T) {
+	dir := writeRelease(t)
+	fp, err := FingerprintDir(dir)
+	if err != nil {
+		t.Fatalf("FingerprintDir failed: %v", err)
+	}
+
+	saved := Known
+	defer func() { Known = saved }()
+
+	// Unknown
+	Known = nil
+	var out bytes.Buffer
+	if e, err := Check(dir, false, &out); e != nil || err != nil {
+		t.Errorf("Expected an unknown edition without error, got %v, %v", e, err)
+	}
+	if !strings.Contains(out.String(), fp["GAME.EXE"]) {
+		t.Errorf("Expected the fingerprint to be printed, got %q", out.String())
+	}
+	if _, err := Check(dir, true, &out); err == nil {
+		t.Error("Expected an error for an unknown edition when a known one is required")
+	}
+
+	// Known
+	Known = []Edition{{Name: "Test release", Fingerprint: fp}}
+	out.Reset()
+	if e, err := Check(dir, true, &out); e == nil || e.Name != "Test release" || err != nil {
+		t.Errorf("Expected the test release, got %v, %v", e, err)
+	}
+
+	// Partly modified
+	if err := os.WriteFile(filepath.Join(dir, "TEXTS.FIL"), []byte("changed"), 0644); err != nil {
+		t.Fatalf("Failed to write TEXTS.FIL: %v", err)
+	}
+	out.Reset()
+	if e, err := Check(dir, false, &out); e != nil || err != nil {
+		t.Errorf("Expected no edition for modified files, got %v, %v", e, err)
+	}
+	if !strings.Contains(out.String(), "Test release, but TEXTS.FIL differ") {
+		t.Errorf("Expected the modified file to be named, got %q", out.String())
+	}
+}
+
+func TestCheckMissingFile(t *testing.T) {
+	dir := writeRelease(t)
+	fp, err := FingerprintDir(dir)
+	if err != nil {
+		t.Fatalf("FingerprintDir failed: %v", err)
+	}
+	saved := Known
+	defer func() { Known = saved }()
+	Known = []Edition{{Name: "Test release", Fingerprint: fp}}
+
+	if err := os.Remove(filepath.Join(dir, "INSTALL.EXE")); err != nil {
+		t.Fatal(err)
+	}
+	missing, err := FingerprintDir(dir)
+	if err != nil {
+		t.Fatalf("Expected a missing file to be absent, not an error: %v", err)
+	}
+	if _, ok := missing["INSTALL.EXE"]; ok || strings.Contains(missing.String(), "INSTALL.EXE") {
+		t.Errorf("Expected INSTALL.EXE to be absent, got %v", missing)
+	}
+
+	var out bytes.Buffer
+	if e, err := Check(dir, false, &out); e != nil || err != nil {
+		t.Errorf("Expected no edition without error, got %v, %v", e, err)
+	}
+	if !strings.Contains(out.String(), "Test release, but INSTALL.EXE differ") {
+		t.Errorf("Expected the missing file not to match, got %q", out.String())
+	}
+}
@@ -0,0 +1,51 @@
+package fil
+
+// Codes of the problems ParseText reports
+const (
+	CodeUnterminatedString = "unterminated-string"
+	CodeUnclosedHex        = "unclosed-hex"
+	CodeUnclosedHeader     = "unclosed-header"
+	CodeInvalidHex         = "invalid-hex"
+	CodeInvalidHeader      = "invalid-header"
+	CodeBadEscape          = "bad-escape"
+	CodeMissingCharset     = "missing-charset"
+	CodeBeforeSection      = "before-section"
+	CodeBadSection         = "bad-section"
+	CodeKeptContents       = "kept-contents"
+	CodeBinaryContents     = "binary-contents"
+	CodeStrayNoNul         = "stray-no-nul"
+	CodeUnknownToken       = "unknown-token"
+	CodeNoSections         = "no-sections"
+)
+
+// Codes of the mismatches Lint reports
+const (
+	CodeSectionCount      = "section-count"
+	CodeBinaryChanged     = "binary-changed"
+	CodeHeaderChanged     = "header-changed"
+	CodeTerminatorChanged = "terminator-changed"
+	CodeRecordCount       = "record-count"
+)
+
+// fixes suggests how to fix each kind of problem
+var fixes = map[string]string{
+	CodeUnterminatedString: "add the closing \" at the end of the string",
+	CodeUnclosedHex:        "add the closing ] on the same line",
+	CodeUnclosedHeader:     "add the closing } on the same line",
+	CodeInvalidHex:         "write pairs of hex digits, e.g. [01 A2 FF]",
+	CodeInvalidHeader:      "give all of id, color, x, y and flags, e.g. {id=0x0102 color=3 x=40 y=120 flags=0x05}",
+	CodeBadEscape:          "use \\n, \\t, \\r, \\\", \\\\ or \\x##",
+	CodeMissingCharset:     "replace the character with one the game's code page 852 font has",
+	CodeBeforeSection:      "start the file with SECTION 0",
+	CodeBadSection:         "number sections 0, 1, 2, ... in order, optionally followed by RECORDS n, STRINGS, BINARY or KEEP",
+	CodeKeptContents:       "remove it; KEEP sections are copied from the original file",
+	CodeBinaryContents:     "BINARY sections hold only [hex] blocks",
+	CodeStrayNoNul:         "NO_NUL may only follow a string",
+	CodeUnknownToken:       "put text in double quotes, or start a comment with ;",
+	CodeNoSections:         "start the file with SECTION 0",
+	CodeSectionCount:       "add or remove sections to match the original",
+	CodeBinaryChanged:      "restore the section's hex blocks from a fresh extract",
+	CodeHeaderChanged:      "restore the header from a fresh extract; only strings may be translated",
+	CodeTerminatorChanged:  "restore the string and NO_NUL markers from a fresh extract",
+	CodeRecordCount:        "add or remove records to match the original",
+}
@@ -0,0 +1,235 @@
+// Package fil reads and writes the .FIL containers used by Mise Quadam
+// (TEXTS.FIL and RESOURCE.FIL).
+//
+// The binary format is:
+//   - a byte indicating how many sections there are
+//   - three bytes per section indicating their offset (from the beginning INCLUDING this directory) into the file
+//   - then three bytes containing the filesize.
+//   - the section data.
+//
+// Text sections are a run of records: a header (normally 5 bytes) followed by
+// a NUL-terminated string, obfuscated by adding 0x31 to every charset byte.
+// Other sections hold binary data; see Layout.
+package fil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/chadlyb/qadam/shared"
+)
+
+// HeaderSize is the number of header bytes preceding each string in text sections.
+const HeaderSize = 5
+
+// Key is added to every charset byte of a string to obfuscate it.
+const Key = 0x31
+
+// MaxSize is the largest file the 24-bit directory can describe.
+const MaxSize = 0xFFFFFF
+
+// File is a decoded .FIL container.
+type File struct {
+	Sections []Section
+}
+
+// Section is one entry of the directory.
+//
+// Records and Strings sections keep their contents in Records, Binary
+// sections in Data. Kept sections are empty until spliced.
+type Section struct {
+	Layout  Layout
+	Records []Record
+	Data    []byte
+	Pos     Pos // where the section starts in the text form, if parsed
+}
+
+// Record is a header followed by an optional string.
+//
+// A record whose section ends before the string's NUL terminator has NoNul
+// set. A record with NoNul set and empty Text carries no string at all,
+// which is how trailing header bytes at the end of a section are kept.
+type Record struct {
+	Header []byte
+	Text   string
+	NoNul  bool
+	Pos    Pos // where the record starts in the text form, if parsed
+}
+
+// HasText reports whether the record carries a string.
+func (r Record) HasText() bool {
+	return !r.NoNul || r.Text != ""
+}
+
+func readInt24(data []byte, offset int) int {
+	return int(data[offset]) | int(data[offset+1])<<8 | int(data[offset+2])<<16
+}
+
+func appendInt24(out []byte, v int) []byte {
+	return append(out, byte(v&0xFF), byte((v>>8)&0xFF), byte((v>>16)&0xFF))
+}
+
+// Decode parses the binary contents of a .FIL file, detecting the layout of every section.
+func Decode(data []byte) (*File, error) {
+	return DecodeWithLayouts(data, nil)
+}
+
+// DecodeWithLayouts parses the binary contents of a .FIL file. Sections
+// listed in layouts use the given layout, the rest are detected.
+func DecodeWithLayouts(data []byte, layouts map[int]Layout) (*File, error) {
+	if len(data) < 1 {
+		return nil, errors.New("data is empty")
+	}
+
+	numSections := int(data[0])
+	dirSize := 1 + (numSections+1)*3
+	if dirSize > len(data) {
+		return nil, fmt.Errorf("directory of %v sections exceeds data size %v", numSections, len(data))
+	}
+
+	offsets := make([]int, numSections+1)
+	for i := range offsets {
+		offsets[i] = readInt24(data, 1+i*3)
+		if offsets[i] < dirSize || offsets[i] > len(data) {
+			return nil, fmt.Errorf("offset %v is out of bounds", offsets[i])
+		}
+		if i > 0 && offsets[i] < offsets[i-1] {
+			return nil, fmt.Errorf("offset %v of section %v precedes previous section", offsets[i], i)
+		}
+	}
+
+	if offsets[0] != dirSize {
+		return nil, fmt.Errorf("first offset %v does not follow directory (size %v)", offsets[0], dirSize)
+	}
+	if offsets[numSections] != len(data) {
+		return nil, fmt.Errorf("last offset %v does not match data size %v", offsets[numSections], len(data))
+	}
+
+	f := &File{Sections: make([]Section, numSections)}
+	for i := range f.Sections {
+		sectionData := data[offsets[i]:offsets[i+1]]
+		layout, ok := layouts[i]
+		if !ok {
+			layout = DetectLayout(sectionData)
+		}
+		f.Sections[i] = decodeSection(sectionData, layout)
+	}
+	return f, nil
+}
+
+func decodeSection(data []byte, layout Layout) Section {
+	s := Section{Layout: layout}
+	if layout.Kind == Binary {
+		s.Data = clone(data)
+		return s
+	}
+
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < layout.headerBytes() {
+			s.Records = append(s.Records, Record{Header: clone(data[pos:]), NoNul: true})
+			break
+		}
+
+		rec := Record{Header: clone(data[pos : pos+layout.headerBytes()])}
+		pos += layout.headerBytes()
+
+		end := bytes.IndexByte(data[pos:], 0)
+		if end == -1 {
+			rec.NoNul = true
+			end = len(data)
+		} else {
+			end += pos
+		}
+		rec.Text = decodeString(data[pos:end])
+		s.Records = append(s.Records, rec)
+		pos = end + 1
+	}
+	return s
+}
+
+func decodeString(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, v := range data {
+		b := v - Key // unobfuscate.
+		switch b {
+		case '\n', '\t':
+			runes[i] = rune(b)
+		default:
+			runes[i] = shared.CharsetRunes[b]
+		}
+	}
+	return string(runes)
+}
+
+// EncodeString converts text to its obfuscated charset bytes, without a NUL terminator.
+func EncodeString(text string) ([]byte, error) {
+	out := make([]byte, 0, len(text))
+	for _, ch := range text {
+		enc, ok := shared.CharsetMapToByte[ch]
+		if !ok {
+			return nil, fmt.Errorf("character %q missing from charset", ch)
+		}
+		out = append(out, enc+Key)
+	}
+	return out, nil
+}
+
+// Encode returns the binary contents of the .FIL file.
+func (f *File) Encode() ([]byte, error) {
+	numSections := len(f.Sections)
+	if numSections > 0xFF {
+		return nil, fmt.Errorf("too many sections (%v)", numSections)
+	}
+
+	dirSize := 1 + (numSections+1)*3
+	var body []byte
+	offsets := make([]int, numSections)
+	for i, s := range f.Sections {
+		offsets[i] = dirSize + len(body)
+		switch s.Layout.Kind {
+		case Binary:
+			body = append(body, s.Data...)
+			continue
+		case Kept:
+			return nil, fmt.Errorf("section %v must be spliced from the original before encoding", i)
+		}
+		for j, r := range s.Records {
+			enc, err := r.Encode()
+			if err != nil {
+				return nil, fmt.Errorf("section %v record %v: %w", i, j, err)
+			}
+			body = append(body, enc...)
+		}
+	}
+
+	totalSize := dirSize + len(body)
+	if totalSize > MaxSize {
+		return nil, fmt.Errorf("file size %v exceeds maximum %v", totalSize, MaxSize)
+	}
+
+	out := make([]byte, 0, totalSize)
+	out = append(out, byte(numSections))
+	for _, offs := range offsets {
+		out = appendInt24(out, offs)
+	}
+	out = appendInt24(out, totalSize)
+	return append(out, body...), nil
+}
+
+// Encode returns the binary form of the record.
+func (r Record) Encode() ([]byte, error) {
+	enc, err := EncodeString(r.Text)
+	if err != nil {
+		return nil, err
+	}
+	out := append(clone(r.Header), enc...)
+	if !r.NoNul {
+		out = append(out, 0)
+	}
+	return out, nil
+}
+
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
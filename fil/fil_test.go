@@ -0,0 +1,119 @@
+package fil
+
+import (
+	"bytes"
+	"testing"
+)
+
+// buildFil assembles a .FIL image from raw section bodies
+func buildFil(sections ...[]byte) []byte {
+	dirSize := 1 + (len(sections)+1)*3
+	data := []byte{byte(len(sections))}
+	offs := dirSize
+	for _, s := range sections {
+		data = appendInt24(data, offs)
+		offs += len(s)
+	}
+	data = appendInt24(data, offs)
+	for _, s := range sections {
+		data = append(data, s...)
+	}
+	return data
+}
+
+func TestDecode(t *testing.T) {
+	// "Hi" in charset: H=0x48, i=0x69; obfuscated with +0x31: 0x79, 0x9A
+	data := buildFil(
+		[]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A, 0x00, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x79},
+		[]byte{0x0B, 0x0C},
+		[]byte{},
+	)
+
+	f, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if len(f.Sections) != 3 {
+		t.Fatalf("Expected 3 sections, got %d", len(f.Sections))
+	}
+
+	recs := f.Sections[0].Records
+	if len(recs) != 2 {
+		t.Fatalf("Expected 2 records in section 0, got %d", len(recs))
+	}
+	if !bytes.Equal(recs[0].Header, []byte{0x01, 0x02, 0x03, 0x04, 0x05}) || recs[0].Text != "Hi" || recs[0].NoNul {
+		t.Errorf("Unexpected first record: %+v", recs[0])
+	}
+	if recs[1].Text != "H" || !recs[1].NoNul {
+		t.Errorf("Expected unterminated 'H' record, got %+v", recs[1])
+	}
+
+	recs = f.Sections[1].Records
+	if len(recs) != 1 || !bytes.Equal(recs[0].Header, []byte{0x0B, 0x0C}) || recs[0].HasText() {
+		t.Errorf("Expected a single header-only record in section 1, got %+v", recs)
+	}
+
+	if len(f.Sections[2].Records) != 0 {
+		t.Errorf("Expected section 2 to be empty, got %+v", f.Sections[2].Records)
+	}
+
+	encoded, err := f.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(encoded, data) {
+		t.Errorf("Round trip mismatch.\nExpected %X\nGot      %X", data, encoded)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"Empty", []byte{}},
+		{"Truncated directory", []byte{0x02, 0x0A, 0x00}},
+		{"Wrong file size", []byte{0x01, 0x07, 0x00, 0x00, 0x09, 0x00, 0x00, 0x00}},
+		{"Offset inside directory", []byte{0x01, 0x02, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00}},
+		{"Decreasing offsets", []byte{0x02, 0x0A, 0x00, 0x00, 0x09, 0x00, 0x00, 0x0B, 0x00, 0x00, 0x00}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Decode(tc.data); err == nil {
+				t.Errorf("Expected error decoding %X", tc.data)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	f := &File{Sections: []Section{
+		{Records: []Record{{Header: []byte{1, 2, 3, 4, 5}, Text: "Ahoj\nsvěte"}}},
+	}}
+
+	encoded, err := f.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// Header: 1 byte + 2 * 3 bytes = 7 bytes, then 5 header bytes, 10 chars and a NUL
+	if len(encoded) != 7+5+10+1 {
+		t.Fatalf("Unexpected encoded length %d", len(encoded))
+	}
+	if readInt24(encoded, 4) != len(encoded) {
+		t.Errorf("File size field %d doesn't match length %d", readInt24(encoded, 4), len(encoded))
+	}
+	if encoded[7+5+4] != '\n'+Key {
+		t.Errorf("Expected obfuscated newline, got %02X", encoded[7+5+4])
+	}
+	if encoded[len(encoded)-1] != 0 {
+		t.Error("Expected NUL terminator")
+	}
+
+	f.Sections[0].Records[0].Text = "€"
+	if _, err := f.Encode(); err == nil {
+		t.Error("Expected error encoding character missing from charset")
+	}
+}
@@ -0,0 +1,106 @@
+package fil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HeaderFields is the decoded form of a 5-byte record header.
+//
+// The layout is our best reading of the game data:
+//   - bytes 0-1: identifier (little-endian), used to look the string up
+//   - byte 2: low nibble is the text color, high nibble holds flags
+//   - byte 3: screen X position
+//   - byte 4: screen Y position
+//
+// Whatever the fields really mean, the mapping is one-to-one, so headers
+// survive a trip through the named form unchanged.
+type HeaderFields struct {
+	ID    uint16
+	Color uint8
+	X     uint8
+	Y     uint8
+	Flags uint8
+}
+
+// DecodeHeader interprets a record header. It fails unless the header is HeaderSize bytes long.
+func DecodeHeader(header []byte) (HeaderFields, bool) {
+	if len(header) != HeaderSize {
+		return HeaderFields{}, false
+	}
+	return HeaderFields{
+		ID:    uint16(header[0]) | uint16(header[1])<<8,
+		Color: header[2] & 0x0F,
+		Flags: header[2] >> 4,
+		X:     header[3],
+		Y:     header[4],
+	}, true
+}
+
+// Bytes returns the raw header.
+func (h HeaderFields) Bytes() []byte {
+	return []byte{byte(h.ID), byte(h.ID >> 8), h.Color&0x0F | h.Flags<<4, h.X, h.Y}
+}
+
+// String returns the named form used in texts.txt.
+func (h HeaderFields) String() string {
+	return fmt.Sprintf("{id=0x%04X color=%v x=%v y=%v flags=0x%02X}", h.ID, h.Color, h.X, h.Y, h.Flags)
+}
+
+// Fields returns the decoded header of the record, if it has the standard size.
+func (r Record) Fields() (HeaderFields, bool) {
+	return DecodeHeader(r.Header)
+}
+
+// ParseHeaderFields parses the inside of a named header, e.g. "id=0x0102 color=3 x=40 y=120 flags=0x05".
+// Every field must be given exactly once, in any order.
+func ParseHeaderFields(s string) (HeaderFields, error) {
+	var h HeaderFields
+	seen := map[string]bool{}
+	for _, field := range strings.Fields(s) {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return h, fmt.Errorf("header field '%v' is not name=value", field)
+		}
+		name = strings.ToLower(name)
+		if seen[name] {
+			return h, fmt.Errorf("header field '%v' given twice", name)
+		}
+		seen[name] = true
+
+		var bits, max uint64 = 8, 0xFF
+		switch name {
+		case "id":
+			bits, max = 16, 0xFFFF
+		case "color", "flags":
+			max = 0x0F
+		case "x", "y":
+		default:
+			return h, fmt.Errorf("unknown header field '%v'", name)
+		}
+		v, err := strconv.ParseUint(value, 0, int(bits))
+		if err != nil || v > max {
+			return h, fmt.Errorf("invalid value '%v' for header field '%v' (max %v)", value, name, max)
+		}
+
+		switch name {
+		case "id":
+			h.ID = uint16(v)
+		case "color":
+			h.Color = uint8(v)
+		case "flags":
+			h.Flags = uint8(v)
+		case "x":
+			h.X = uint8(v)
+		case "y":
+			h.Y = uint8(v)
+		}
+	}
+	for _, name := range []string{"id", "color", "x", "y", "flags"} {
+		if !seen[name] {
+			return h, fmt.Errorf("header is missing field '%v'", name)
+		}
+	}
+	return h, nil
+}
@@ -0,0 +1,64 @@
+package fil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeHeader(t *testing.T) {
+	h, ok := DecodeHeader([]byte{0x02, 0x01, 0x53, 0x28, 0x78})
+	if !ok {
+		t.Fatal("DecodeHeader rejected a 5-byte header")
+	}
+
+	expected := HeaderFields{ID: 0x0102, Color: 3, X: 40, Y: 120, Flags: 0x05}
+	if h != expected {
+		t.Errorf("Expected %+v, got %+v", expected, h)
+	}
+	if h.String() != "{id=0x0102 color=3 x=40 y=120 flags=0x05}" {
+		t.Errorf("Unexpected named form %v", h)
+	}
+
+	if _, ok := DecodeHeader([]byte{1, 2, 3}); ok {
+		t.Error("Expected short header to be rejected")
+	}
+}
+
+func TestHeaderFieldsRoundTrip(t *testing.T) {
+	// Every header byte pattern must survive the named form unchanged
+	for b := 0; b < 256; b++ {
+		header := []byte{byte(b), byte(255 - b), byte(b), byte(b * 7), byte(b * 13)}
+		h, _ := DecodeHeader(header)
+		parsed, err := ParseHeaderFields(h.String()[1 : len(h.String())-1])
+		if err != nil {
+			t.Fatalf("ParseHeaderFields(%v) failed: %v", h, err)
+		}
+		if !bytes.Equal(parsed.Bytes(), header) {
+			t.Fatalf("Round trip mismatch: %X -> %v -> %X", header, h, parsed.Bytes())
+		}
+	}
+}
+
+func TestParseHeaderFieldsErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"Missing field", "id=1 color=2 x=3 y=4"},
+		{"Duplicate field", "id=1 id=1 color=2 x=3 y=4 flags=5"},
+		{"Unknown field", "id=1 color=2 x=3 y=4 flags=5 z=6"},
+		{"Color too large", "id=1 color=16 x=3 y=4 flags=5"},
+		{"Flags too large", "id=1 color=2 x=3 y=4 flags=0x10"},
+		{"Byte too large", "id=1 color=2 x=256 y=4 flags=5"},
+		{"Not name=value", "id=1 color x=3 y=4 flags=5"},
+		{"Not a number", "id=abc color=2 x=3 y=4 flags=5"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseHeaderFields(tc.input); err == nil {
+				t.Errorf("Expected error parsing %q", tc.input)
+			}
+		})
+	}
+}
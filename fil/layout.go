@@ -0,0 +1,193 @@
+package fil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/chadlyb/qadam/shared"
+)
+
+// Kind says how a section's data is organized.
+type Kind int
+
+const (
+	// Records are a header followed by a NUL-terminated string.
+	Records Kind = iota
+	// Strings are NUL-terminated strings without headers.
+	Strings
+	// Binary sections are kept as opaque data.
+	Binary
+	// Kept sections have no contents of their own; they are copied
+	// unchanged from the original file by Splice.
+	Kept
+)
+
+// Layout describes how to split a section into records.
+//
+// The zero Layout is the layout of the game's text sections.
+type Layout struct {
+	Kind Kind
+	// HeaderSize is the number of header bytes before each string in
+	// Records sections; zero means the standard HeaderSize.
+	HeaderSize int
+}
+
+// DefaultLayout is the layout of the game's text sections.
+var DefaultLayout = Layout{}
+
+// headerBytes returns the number of header bytes before each string.
+func (l Layout) headerBytes() int {
+	switch {
+	case l.Kind != Records:
+		return 0
+	case l.HeaderSize == 0:
+		return HeaderSize
+	default:
+		return l.HeaderSize
+	}
+}
+
+// IsDefault reports whether l is the layout of the game's text sections.
+func (l Layout) IsDefault() bool {
+	return l.Kind == Records && l.headerBytes() == HeaderSize
+}
+
+// String returns the layout as written after SECTION N in the text form.
+func (l Layout) String() string {
+	switch l.Kind {
+	case Binary:
+		return "BINARY"
+	case Kept:
+		return "KEEP"
+	case Strings:
+		return "STRINGS"
+	default:
+		return fmt.Sprintf("RECORDS %v", l.headerBytes())
+	}
+}
+
+// KnownLayouts lists sections whose layout is known, by file name and section
+// index. Sections not listed here are detected with DetectLayout.
+var KnownLayouts = map[string]map[int]Layout{
+	"TEXTS.FIL":    {0: DefaultLayout},
+	"RESOURCE.FIL": {11: DefaultLayout},
+}
+
+// LayoutsFor returns the known section layouts of the named file, or nil.
+func LayoutsFor(name string) map[int]Layout {
+	return KnownLayouts[strings.ToUpper(name)]
+}
+
+// EditableSections lists, by file name, the only sections translators
+// should see. The other sections of these files are extracted as Kept.
+// Files not listed here are editable throughout.
+var EditableSections = map[string][]int{
+	"RESOURCE.FIL": {11},
+}
+
+// EditableFor returns the editable sections of the named file, or nil if all are.
+func EditableFor(name string) []int {
+	return EditableSections[strings.ToUpper(name)]
+}
+
+// maxDetectedHeaderSize bounds the header sizes DetectLayout tries.
+const maxDetectedHeaderSize = 16
+
+// minDetectSize is the smallest section DetectLayout judges; smaller
+// sections don't hold enough evidence and keep the DefaultLayout.
+const minDetectSize = 64
+
+// minDetectRecords is the fewest records a candidate layout must split a
+// section into.
+const minDetectRecords = 4
+
+// minTextCoverage is the fraction of a section that must be human-readable
+// strings before DetectLayout treats it as text.
+const minTextCoverage = 0.5
+
+// DetectLayout guesses the layout of a section.
+//
+// Each candidate header size (5 first, then plain strings, then others) is
+// tried; a candidate is rejected if any string holds control characters or
+// the section ends inside a header. The candidate whose human-readable
+// strings cover most of the section wins, and sections where no candidate
+// reaches minTextCoverage are Binary.
+func DetectLayout(data []byte) Layout {
+	if len(data) < minDetectSize {
+		return DefaultLayout
+	}
+
+	candidates := []Layout{DefaultLayout, {Kind: Strings}}
+	for h := 1; h <= maxDetectedHeaderSize; h++ {
+		if h != HeaderSize {
+			candidates = append(candidates, Layout{Kind: Records, HeaderSize: h})
+		}
+	}
+
+	best := Layout{Kind: Binary}
+	bestCoverage := 0
+	for _, l := range candidates {
+		coverage, ok := textCoverage(data, l.headerBytes())
+		if ok && coverage > bestCoverage {
+			best = l
+			bestCoverage = coverage
+		}
+	}
+
+	if float64(bestCoverage) < minTextCoverage*float64(len(data)) {
+		return Layout{Kind: Binary}
+	}
+	return best
+}
+
+// textCoverage splits data into records with the given header size and
+// returns how many bytes belong to human-readable strings.
+func textCoverage(data []byte, headerSize int) (int, bool) {
+	coverage := 0
+	records := 0
+	for pos := 0; pos < len(data); records++ {
+		if len(data)-pos < headerSize {
+			return 0, false
+		}
+		pos += headerSize
+
+		// Plain string lists start every string with a letter
+		if headerSize == 0 && !shared.IsAcceptableStringStart(data[pos]-Key) {
+			return 0, false
+		}
+
+		start := pos
+		for pos < len(data) && data[pos] != 0 {
+			b := data[pos] - Key
+			if b < 0x20 && b != '\n' && b != '\t' {
+				return 0, false
+			}
+			pos++
+		}
+
+		text := make([]byte, pos-start)
+		for i := range text {
+			text[i] = data[start+i] - Key
+		}
+		if looksLikeText(text) {
+			coverage += len(text) + 1
+		}
+		pos++ // NUL
+	}
+	return coverage, records >= minDetectRecords
+}
+
+// looksLikeText reports whether an unobfuscated string reads as language.
+// Tables of small numbers map onto accented capitals in the charset, so a
+// string also needs plain ASCII letters to count.
+func looksLikeText(text []byte) bool {
+	if !shared.IsLikelyHumanLanguage(text) {
+		return false
+	}
+	for _, b := range text {
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package fil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var sampleTexts = []string{
+	"Ahoj světe",
+	"Kde je klíč od dveří?",
+	"Tady nic není.",
+	"Vezmi si lampu",
+	"Dveře jsou zamčené",
+	"Nemohu to otevřít",
+	"To je zajímavé",
+	"Půjdeme dál",
+}
+
+// recordSection builds a section of records with headers of the given size
+func recordSection(t *testing.T, headerSize int) []byte {
+	var data []byte
+	for i, text := range sampleTexts {
+		header := make([]byte, headerSize)
+		for j := range header {
+			header[j] = byte(0x40 + i + j) // screen coordinates land in the control range
+		}
+		enc, err := EncodeString(text)
+		if err != nil {
+			t.Fatalf("EncodeString(%q) failed: %v", text, err)
+		}
+		data = append(data, header...)
+		data = append(data, enc...)
+		data = append(data, 0)
+	}
+	return data
+}
+
+func TestDetectLayout(t *testing.T) {
+	binary := make([]byte, 200)
+	for i := range binary {
+		binary[i] = byte(i % 7)
+	}
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected Layout
+	}{
+		{"Records", recordSection(t, HeaderSize), DefaultLayout},
+		{"Strings", recordSection(t, 0), Layout{Kind: Strings}},
+		{"Three byte headers", recordSection(t, 3), Layout{Kind: Records, HeaderSize: 3}},
+		{"Binary", binary, Layout{Kind: Binary}},
+		{"Too small to judge", []byte{0x00, 0x01, 0x02}, DefaultLayout},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := DetectLayout(tc.data)
+			if got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestLayoutTextRoundTrip(t *testing.T) {
+	binary := make([]byte, 100)
+	for i := range binary {
+		binary[i] = byte(i * 3)
+	}
+	data := buildFil(recordSection(t, HeaderSize), recordSection(t, 0), recordSection(t, 3), binary)
+
+	f, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	var text bytes.Buffer
+	if err := f.WriteText(&text); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	output := text.String()
+	t.Logf("Output: %s", output)
+
+	for _, expected := range []string{"SECTION 0\n", "SECTION 1 STRINGS\n", "SECTION 2 RECORDS 3\n", "SECTION 3 BINARY\n"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected to find %q in output", expected)
+		}
+	}
+	if strings.Count(output, "\"Ahoj světe\"") != 3 {
+		t.Error("Expected 'Ahoj světe' once in each text section")
+	}
+
+	parsed, err := ParseText(&text)
+	if err != nil {
+		t.Fatalf("ParseText failed: %v", err)
+	}
+	for i := range f.Sections {
+		if parsed.Sections[i].Layout != f.Sections[i].Layout {
+			t.Errorf("Section %d layout %v parsed as %v", i, f.Sections[i].Layout, parsed.Sections[i].Layout)
+		}
+	}
+
+	encoded, err := parsed.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(encoded, data) {
+		t.Errorf("Round trip mismatch.\nExpected %X\nGot      %X", data, encoded)
+	}
+}
+
+func TestDecodeWithLayouts(t *testing.T) {
+	// A known layout wins over detection, even for data that looks like text
+	data := buildFil(recordSection(t, 0))
+
+	f, err := DecodeWithLayouts(data, map[int]Layout{0: {Kind: Binary}})
+	if err != nil {
+		t.Fatalf("DecodeWithLayouts failed: %v", err)
+	}
+	if f.Sections[0].Layout.Kind != Binary || len(f.Sections[0].Data) != len(data)-7 {
+		t.Errorf("Expected binary section, got %+v", f.Sections[0])
+	}
+
+	if LayoutsFor("texts.fil")[0] != DefaultLayout {
+		t.Error("Expected TEXTS.FIL section 0 to have a known layout")
+	}
+}
+
+func TestBinarySectionRejectsStrings(t *testing.T) {
+	if _, err := ParseText(strings.NewReader("SECTION 0 BINARY\n[00 01] \"text\"\n")); err == nil {
+		t.Error("Expected error for string in BINARY section")
+	}
+}
@@ -0,0 +1,223 @@
+package fil
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chadlyb/qadam/shared"
+)
+
+// Pos is a position in the text form; Line and Column count from 1, Column in characters.
+type Pos struct {
+	Line   int
+	Column int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// TokenKind identifies what a Token holds.
+type TokenKind int
+
+const (
+	// WordToken is a bare word such as SECTION, a number, or NO_NUL.
+	WordToken TokenKind = iota
+	// HexToken is a [hex] block; Text holds what's between the brackets.
+	HexToken
+	// HeaderToken is a {named header}; Text holds what's between the braces.
+	HeaderToken
+	// StringToken is a quoted string; Text holds what's between the quotes, still escaped.
+	StringToken
+)
+
+// Token is a lexical element of the text form.
+type Token struct {
+	Kind TokenKind
+	Text string
+	Pos  Pos
+}
+
+// PosError is an error at a position in the text form.
+// Code names the kind of problem, as used in diagnostics.
+type PosError struct {
+	Pos  Pos
+	Code string
+	Err  error
+}
+
+func (e *PosError) Error() string {
+	if e.Pos.Line == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("line %v: %v", e.Pos, e.Err)
+}
+
+func (e *PosError) Unwrap() error {
+	return e.Err
+}
+
+// Diagnostic returns the error as a diagnostic in the named file.
+func (e *PosError) Diagnostic(file string) shared.Diagnostic {
+	return shared.Diagnostic{
+		File:     file,
+		Line:     e.Pos.Line,
+		Column:   e.Pos.Column,
+		Severity: shared.SeverityError,
+		Code:     e.Code,
+		Message:  e.Err.Error(),
+		Fix:      fixes[e.Code],
+	}
+}
+
+func errorAt(pos Pos, code string, format string, args ...any) *PosError {
+	return &PosError{Pos: pos, Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// lexer splits the text form into tokens, keeping track of positions.
+type lexer struct {
+	src string
+	at  int
+	pos Pos
+}
+
+// Lex splits the text form into tokens.
+//
+// Whitespace separates tokens and ';' starts a comment running to the end
+// of the line, except inside strings, hex blocks and headers. Hex blocks
+// and headers must close on the line they open. Strings are kept exactly
+// as written, and may span several lines; a line break inside a string is
+// a newline character (CRLF counts as one).
+//
+// The error, if any, is the first *PosError; LexAll returns them all.
+func Lex(src string) ([]Token, error) {
+	tokens, errs := LexAll(src)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return tokens, nil
+}
+
+// LexAll is Lex, but carries on past errors and returns every one of them.
+//
+// A string that is still open when a line starts with '[', '{' or SECTION
+// is taken to be missing its closing quote, and lexing resumes at that line.
+func LexAll(src string) ([]Token, []*PosError) {
+	l := &lexer{src: strings.TrimPrefix(src, "\uFEFF"), pos: Pos{Line: 1, Column: 1}}
+
+	var tokens []Token
+	var errs []*PosError
+	for {
+		l.skipSpaceAndComments()
+		if l.at >= len(l.src) {
+			return tokens, errs
+		}
+
+		start := l.pos
+		switch l.peek() {
+		case '"':
+			l.next()
+			text, ok := l.until('"', true, false)
+			if !ok {
+				errs = append(errs, errorAt(start, CodeUnterminatedString, "unterminated quoted string"))
+				continue
+			}
+			tokens = append(tokens, Token{StringToken, text, start})
+		case '[':
+			l.next()
+			text, ok := l.until(']', false, true)
+			if !ok {
+				errs = append(errs, errorAt(start, CodeUnclosedHex, "missing closing ] for hex block"))
+				continue
+			}
+			tokens = append(tokens, Token{HexToken, text, start})
+		case '{':
+			l.next()
+			text, ok := l.until('}', false, true)
+			if !ok {
+				errs = append(errs, errorAt(start, CodeUnclosedHeader, "missing closing } for header"))
+				continue
+			}
+			tokens = append(tokens, Token{HeaderToken, text, start})
+		default:
+			var sb strings.Builder
+			for l.at < len(l.src) && !isSpace(l.peek()) && !strings.ContainsRune(";\"[{", l.peek()) {
+				sb.WriteRune(l.next())
+			}
+			tokens = append(tokens, Token{WordToken, sb.String(), start})
+		}
+	}
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r' || r == '\n'
+}
+
+func (l *lexer) peek() rune {
+	r, _ := utf8.DecodeRuneInString(l.src[l.at:])
+	return r
+}
+
+func (l *lexer) next() rune {
+	r, size := utf8.DecodeRuneInString(l.src[l.at:])
+	l.at += size
+	if r == '\n' {
+		l.pos.Line++
+		l.pos.Column = 1
+	} else {
+		l.pos.Column++
+	}
+	return r
+}
+
+func (l *lexer) skipSpaceAndComments() {
+	for l.at < len(l.src) {
+		switch r := l.peek(); {
+		case isSpace(r):
+			l.next()
+		case r == ';':
+			for l.at < len(l.src) && l.peek() != '\n' {
+				l.next()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// until consumes up to and including the closing delimiter, returning the
+// text before it. If escapes is set, a backslash escapes the next character.
+// If singleLine is set, the text may not span lines; otherwise it may,
+// unless the next line looks like the start of a record or section.
+// On failure, the lexer is left at the start of the line that ended the text.
+func (l *lexer) until(closing rune, escapes, singleLine bool) (string, bool) {
+	var sb strings.Builder
+	for l.at < len(l.src) {
+		if l.peek() == '\n' && (singleLine || l.recordStartsAfterLineBreak()) {
+			l.next()
+			return "", false
+		}
+		r := l.next()
+		switch {
+		case r == closing:
+			return sb.String(), true
+		case r == '\r' && l.peek() == '\n':
+			// CRLF line break; the \n is kept
+		case escapes && r == '\\' && l.at < len(l.src):
+			sb.WriteRune(r)
+			sb.WriteRune(l.next())
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return "", false
+}
+
+// recordStartsAfterLineBreak reports whether the line after the line break
+// at the current position starts with a record header or SECTION.
+func (l *lexer) recordStartsAfterLineBreak() bool {
+	line := strings.TrimLeft(l.src[l.at+1:], " \t")
+	return strings.HasPrefix(line, "[") || strings.HasPrefix(line, "{") ||
+		strings.HasPrefix(line, "SECTION ")
+}
@@ -0,0 +1,177 @@
+package fil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLex(t *testing.T) {
+	src := "SECTION 0 ; comment \"not a string\"\n" +
+		"{id=1 color=2\tx=3 y=4 flags=5} \"two  spaces\tand a tab; not a comment\"\n" +
+		"  [01 02 03] \"line one\r\nline two\" NO_NUL\n"
+
+	tokens, err := Lex(src)
+	if err != nil {
+		t.Fatalf("Lex failed: %v", err)
+	}
+
+	expected := []Token{
+		{WordToken, "SECTION", Pos{1, 1}},
+		{WordToken, "0", Pos{1, 9}},
+		{HeaderToken, "id=1 color=2\tx=3 y=4 flags=5", Pos{2, 1}},
+		{StringToken, "two  spaces\tand a tab; not a comment", Pos{2, 32}},
+		{HexToken, "01 02 03", Pos{3, 3}},
+		{StringToken, "line one\nline two", Pos{3, 14}},
+		{WordToken, "NO_NUL", Pos{4, 11}},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d: %+v", len(expected), len(tokens), tokens)
+	}
+	for i := range expected {
+		if tokens[i] != expected[i] {
+			t.Errorf("Token %d: expected %+v, got %+v", i, expected[i], tokens[i])
+		}
+	}
+}
+
+func TestLexEscapesAndCharacters(t *testing.T) {
+	// A leading byte order mark is skipped, columns count characters, not bytes,
+	// and an escaped quote doesn't end a string
+	tokens, err := Lex("\uFEFF\"Čau \\\"ty\\\"\" x")
+	if err != nil {
+		t.Fatalf("Lex failed: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].Text != "Čau \\\"ty\\\"" || tokens[1].Pos != (Pos{1, 14}) {
+		t.Errorf("Unexpected tokens %+v", tokens)
+	}
+}
+
+func TestLexErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		pos  Pos
+	}{
+		{"Unterminated string", "SECTION 0\n  [01] \"abc\n", Pos{2, 8}},
+		{"Unclosed hex", "SECTION 0\n[01 02\n", Pos{2, 1}},
+		{"Unclosed header", "SECTION 0\n\n {id=1\n", Pos{3, 2}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Lex(tc.src)
+			var posErr *PosError
+			if !errors.As(err, &posErr) {
+				t.Fatalf("Expected PosError, got %v", err)
+			}
+			if posErr.Pos != tc.pos {
+				t.Errorf("Expected error at %v, got %v", tc.pos, posErr.Pos)
+			}
+		})
+	}
+}
+
+func TestParseTextPreservesStrings(t *testing.T) {
+	input := "SECTION 0\n" +
+		"[01 02 03 04 05] \"Two  spaces,\ta tab\"\n" +
+		"[01 02 03 04 05] \"First line\n  second line\"\n"
+
+	f, err := ParseText(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseText failed: %v", err)
+	}
+
+	recs := f.Sections[0].Records
+	if recs[0].Text != "Two  spaces,\ta tab" {
+		t.Errorf("Expected spacing to be preserved, got %q", recs[0].Text)
+	}
+	if recs[1].Text != "First line\n  second line" {
+		t.Errorf("Expected multi-line string, got %q", recs[1].Text)
+	}
+	if recs[1].Pos != (Pos{3, 1}) {
+		t.Errorf("Expected second record at 3:1, got %v", recs[1].Pos)
+	}
+}
+
+func TestParseTextErrorPosition(t *testing.T) {
+	_, err := ParseText(strings.NewReader("SECTION 0\n[01 02 03 04 05] \"Ahoj\"\n[01 02 03 04 05]  \"€\"\n"))
+	var posErr *PosError
+	if !errors.As(err, &posErr) {
+		t.Fatalf("Expected PosError, got %v", err)
+	}
+	if posErr.Pos != (Pos{3, 20}) {
+		t.Errorf("Expected error at 3:20, got %v", posErr.Pos)
+	}
+	if err.Error() != "line 3:20: character '€' missing from charset" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+}
+
+func TestLexAllRecovers(t *testing.T) {
+	src := "SECTION 0\n" +
+		"[01 02 03 04 05] \"missing quote\n" +
+		"[01 02 03 04 05 \"ok\"\n" +
+		"  {id=1 color=2 x=3 y=4 flags=5} \"fine\"\n"
+
+	tokens, errs := LexAll(src)
+	expected := []*PosError{
+		{Pos: Pos{2, 18}, Code: CodeUnterminatedString},
+		{Pos: Pos{3, 1}, Code: CodeUnclosedHex},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), errs)
+	}
+	for i, e := range expected {
+		if errs[i].Pos != e.Pos || errs[i].Code != e.Code {
+			t.Errorf("Error %d: expected %v at %v, got %v at %v", i, e.Code, e.Pos, errs[i].Code, errs[i].Pos)
+		}
+	}
+
+	last := tokens[len(tokens)-1]
+	if last.Kind != StringToken || last.Text != "fine" || last.Pos != (Pos{4, 34}) {
+		t.Errorf("Expected lexing to resume after errors, last token %+v", last)
+	}
+}
+
+func TestParseTextAllReportsEveryError(t *testing.T) {
+	input := "SECTION 0\n" +
+		"[01 02 03 04 05] \"Ahoj €\"\n" +
+		"[01 02 03 04 05] \"bad \\q escape\"\n" +
+		"[0G] \"x\"\n" +
+		"{id=1} \"y\"\n" +
+		"NO_NUL\n" +
+		"SECTION 3\n" +
+		"oops\n"
+
+	_, errs, err := ParseTextAll(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseTextAll failed: %v", err)
+	}
+
+	expected := []struct {
+		pos  Pos
+		code string
+	}{
+		{Pos{2, 24}, CodeMissingCharset},
+		{Pos{3, 23}, CodeBadEscape},
+		{Pos{4, 1}, CodeInvalidHex},
+		{Pos{5, 1}, CodeInvalidHeader},
+		{Pos{7, 9}, CodeBadSection},
+		{Pos{8, 1}, CodeUnknownToken},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), errs)
+	}
+	for i, e := range expected {
+		if errs[i].Pos != e.pos || errs[i].Code != e.code {
+			t.Errorf("Error %d: expected %v at %v, got %v", i, e.code, e.pos, errs[i])
+		}
+	}
+
+	d := errs[0].Diagnostic("texts.txt")
+	if d.String() != "texts.txt:2:24: error: character '€' missing from charset [missing-charset]\n\tfix: "+fixes[CodeMissingCharset] {
+		t.Errorf("Unexpected diagnostic %q", d.String())
+	}
+}
@@ -0,0 +1,186 @@
+package fil
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chadlyb/qadam/shared"
+)
+
+// Mismatch is a structural difference between an edited file and its original.
+type Mismatch struct {
+	Section int
+	Record  int // -1 if the mismatch concerns the whole section
+	Code    string
+	Message string
+	Pos     Pos // where the section or record is in the text form, if it came from one
+}
+
+// Diagnostic returns the mismatch as a diagnostic in the named file.
+func (m Mismatch) Diagnostic(file string) shared.Diagnostic {
+	return shared.Diagnostic{
+		File:     file,
+		Line:     m.Pos.Line,
+		Column:   m.Pos.Column,
+		Severity: shared.SeverityError,
+		Code:     m.Code,
+		Message:  m.String(),
+		Fix:      fixes[m.Code],
+	}
+}
+
+func (m Mismatch) String() string {
+	if m.Record < 0 {
+		return fmt.Sprintf("section %v: %v", m.Section, m.Message)
+	}
+	return fmt.Sprintf("section %v record %v: %v", m.Section, m.Record, m.Message)
+}
+
+// Lint compares edited against og, the original binary file, and reports
+// every structural difference: sections, record counts, headers and
+// terminators must all match, only string contents may differ. Kept
+// sections are not compared.
+//
+// og is decoded with the layouts of edited, so both split into the same records.
+func Lint(og []byte, edited *File) ([]Mismatch, error) {
+	layouts := map[int]Layout{}
+	for i, s := range edited.Sections {
+		if s.Layout.Kind != Kept {
+			layouts[i] = s.Layout
+		}
+	}
+	ogFile, err := DecodeWithLayouts(og, layouts)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode original: %w", err)
+	}
+
+	var mismatches []Mismatch
+	if len(edited.Sections) != len(ogFile.Sections) {
+		mismatches = append(mismatches, Mismatch{
+			Section: min(len(edited.Sections), len(ogFile.Sections)),
+			Record:  -1,
+			Code:    CodeSectionCount,
+			Message: fmt.Sprintf("file has %v sections, original has %v", len(edited.Sections), len(ogFile.Sections)),
+		})
+	}
+
+	for i := 0; i < len(edited.Sections) && i < len(ogFile.Sections); i++ {
+		mismatches = append(mismatches, lintSection(i, ogFile.Sections[i], edited.Sections[i])...)
+	}
+	return mismatches, nil
+}
+
+func lintSection(index int, og, edited Section) []Mismatch {
+	var mismatches []Mismatch
+	add := func(record int, code string, format string, args ...any) {
+		pos := edited.Pos
+		if record >= 0 && record < len(edited.Records) {
+			pos = edited.Records[record].Pos
+		}
+		mismatches = append(mismatches, Mismatch{index, record, code, fmt.Sprintf(format, args...), pos})
+	}
+
+	switch edited.Layout.Kind {
+	case Kept:
+		return nil
+	case Binary:
+		if !bytes.Equal(og.Data, edited.Data) {
+			add(-1, CodeBinaryChanged, "%v", describeDataChange(og.Data, edited.Data))
+		}
+		return mismatches
+	}
+
+	shifted := false
+	n := min(len(og.Records), len(edited.Records))
+	for j := 0; j < n; j++ {
+		o, e := og.Records[j], edited.Records[j]
+		if !bytes.Equal(o.Header, e.Header) {
+			add(j, CodeHeaderChanged, "header %v differs from original %v", describeHeader(e.Header), describeHeader(o.Header))
+			if len(og.Records) != len(edited.Records) {
+				// Records were probably added or removed here; everything after is shifted
+				shifted = true
+				break
+			}
+		}
+		if o.HasText() != e.HasText() {
+			add(j, CodeTerminatorChanged, "string was %v", addedOrRemoved(e.HasText()))
+		} else if o.NoNul != e.NoNul {
+			add(j, CodeTerminatorChanged, "NO_NUL was %v", addedOrRemoved(e.NoNul))
+		}
+	}
+
+	if len(og.Records) != len(edited.Records) {
+		if !shifted {
+			if len(edited.Records) > n {
+				add(n, CodeRecordCount, "record %v was added", describeHeader(edited.Records[n].Header))
+			} else {
+				add(n, CodeRecordCount, "record %v was removed", describeHeader(og.Records[n].Header))
+			}
+		}
+		add(-1, CodeRecordCount, "section has %v records, original has %v", len(edited.Records), len(og.Records))
+	}
+	return mismatches
+}
+
+func addedOrRemoved(added bool) string {
+	if added {
+		return "added"
+	}
+	return "removed"
+}
+
+func describeHeader(header []byte) string {
+	if h, ok := DecodeHeader(header); ok {
+		return h.String()
+	}
+	return fmt.Sprintf("[% X]", header)
+}
+
+func describeDataChange(og, edited []byte) string {
+	for i := 0; i < len(og) && i < len(edited); i++ {
+		if og[i] != edited[i] {
+			return fmt.Sprintf("binary data differs from original at byte %v", i)
+		}
+	}
+	return fmt.Sprintf("binary data is %v bytes, original is %v", len(edited), len(og))
+}
+
+// LintFile parses the text form at textPath and lints it against the original
+// binary file at ogPath. Every problem, whether in parsing or a mismatch, is
+// added to diags as coming from textPath's base name; the error is for files
+// that can't be read.
+func LintFile(textPath, ogPath string, diags *shared.Diagnostics) error {
+	og, err := os.ReadFile(ogPath)
+	if err != nil {
+		return fmt.Errorf("couldn't read original: %w", err)
+	}
+
+	text, err := os.Open(textPath)
+	if err != nil {
+		return fmt.Errorf("couldn't open '%v': %w", textPath, err)
+	}
+	defer text.Close()
+
+	name := filepath.Base(textPath)
+	edited, errs, err := ParseTextAll(text)
+	if err != nil {
+		return fmt.Errorf("couldn't read '%v': %w", textPath, err)
+	}
+	for _, e := range errs {
+		diags.Add(e.Diagnostic(name))
+	}
+	if len(errs) > 0 {
+		return nil
+	}
+
+	mismatches, err := Lint(og, edited)
+	if err != nil {
+		return err
+	}
+	for _, m := range mismatches {
+		diags.Add(m.Diagnostic(name))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package fil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	og := buildFil(
+		[]byte{
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A, 0x00, // "Hi"
+			0x06, 0x07, 0x08, 0x09, 0x0A, 0x79, 0x00, // "H"
+		},
+		[]byte{0x0B, 0x0C},
+	)
+
+	testCases := []struct {
+		name     string
+		text     string
+		expected []string
+	}{
+		{
+			"Only strings changed",
+			"SECTION 0\n[01 02 03 04 05] \"Ahoj\"\n[06 07 08 09 0A] \"\"\nSECTION 1\n[0B 0C]\n",
+			nil,
+		},
+		{
+			"Kept section",
+			"SECTION 0\n[01 02 03 04 05] \"Hi\"\n[06 07 08 09 0A] \"H\"\nSECTION 1 KEEP\n",
+			nil,
+		},
+		{
+			"Header changed",
+			"SECTION 0\n[01 02 03 04 05] \"Hi\"\n[06 07 08 09 0B] \"H\"\nSECTION 1\n[0B 0C]\n",
+			[]string{"section 0 record 1: header {id=0x0706 color=8 x=9 y=11 flags=0x00} differs from original {id=0x0706 color=8 x=9 y=10 flags=0x00}"},
+		},
+		{
+			"Record removed",
+			"SECTION 0\n[01 02 03 04 05] \"Hi\"\nSECTION 1\n[0B 0C]\n",
+			[]string{
+				"section 0 record 1: record {id=0x0706 color=8 x=9 y=10 flags=0x00} was removed",
+				"section 0: section has 1 records, original has 2",
+			},
+		},
+		{
+			"Record removed from the middle",
+			"SECTION 0\n[06 07 08 09 0A] \"H\"\nSECTION 1\n[0B 0C]\n",
+			[]string{
+				"section 0 record 0: header {id=0x0706 color=8 x=9 y=10 flags=0x00} differs from original {id=0x0201 color=3 x=4 y=5 flags=0x00}",
+				"section 0: section has 1 records, original has 2",
+			},
+		},
+		{
+			"String removed",
+			"SECTION 0\n[01 02 03 04 05] \"Hi\"\n[06 07 08 09 0A]\nSECTION 1\n[0B 0C]\n",
+			[]string{"section 0 record 1: string was removed"},
+		},
+		{
+			"NO_NUL added",
+			"SECTION 0\n[01 02 03 04 05] \"Hi\"\n[06 07 08 09 0A] \"H\" NO_NUL\nSECTION 1\n[0B 0C]\n",
+			[]string{"section 0 record 1: NO_NUL was added"},
+		},
+		{
+			"Section dropped",
+			"SECTION 0\n[01 02 03 04 05] \"Hi\"\n[06 07 08 09 0A] \"H\"\n",
+			[]string{"section 1: file has 1 sections, original has 2"},
+		},
+		{
+			"Binary changed",
+			"SECTION 0\n[01 02 03 04 05] \"Hi\"\n[06 07 08 09 0A] \"H\"\nSECTION 1 BINARY\n[0B 0D]\n",
+			[]string{"section 1: binary data differs from original at byte 1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			edited, err := ParseText(strings.NewReader(tc.text))
+			if err != nil {
+				t.Fatalf("ParseText failed: %v", err)
+			}
+
+			mismatches, err := Lint(og, edited)
+			if err != nil {
+				t.Fatalf("Lint failed: %v", err)
+			}
+
+			var got []string
+			for _, m := range mismatches {
+				got = append(got, m.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.expected, "\n") {
+				t.Errorf("Expected:\n%v\nGot:\n%v", strings.Join(tc.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
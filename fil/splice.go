@@ -0,0 +1,40 @@
+package fil
+
+import (
+	"fmt"
+	"slices"
+)
+
+// KeepExcept replaces every section not listed in editable with a Kept
+// placeholder, so only the editable sections appear in the text form.
+func (f *File) KeepExcept(editable ...int) {
+	for i := range f.Sections {
+		if !slices.Contains(editable, i) {
+			f.Sections[i] = Section{Layout: Layout{Kind: Kept}}
+		}
+	}
+}
+
+// HasKept reports whether any section is a Kept placeholder.
+func (f *File) HasKept() bool {
+	for _, s := range f.Sections {
+		if s.Layout.Kind == Kept {
+			return true
+		}
+	}
+	return false
+}
+
+// Splice fills every Kept section with the corresponding section of og.
+func (f *File) Splice(og *File) error {
+	for i := range f.Sections {
+		if f.Sections[i].Layout.Kind != Kept {
+			continue
+		}
+		if i >= len(og.Sections) {
+			return fmt.Errorf("kept section %v not found in original (%v sections)", i, len(og.Sections))
+		}
+		f.Sections[i] = og.Sections[i]
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package fil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestKeepAndSplice(t *testing.T) {
+	data := buildFil(
+		[]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A, 0x00},
+		[]byte{0x06, 0x07, 0x08, 0x09, 0x0A, 0x79, 0x00},
+		[]byte{0x0B, 0x0C},
+	)
+
+	og, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	f, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	f.KeepExcept(1)
+	if !f.HasKept() {
+		t.Fatal("Expected kept sections")
+	}
+
+	var text bytes.Buffer
+	if err := f.WriteText(&text); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	output := text.String()
+	t.Logf("Output: %s", output)
+
+	if !strings.Contains(output, "SECTION 0 KEEP\n") || !strings.Contains(output, "SECTION 2 KEEP\n") {
+		t.Error("Expected sections 0 and 2 to be kept")
+	}
+	if strings.Contains(output, "\"Hi\"") {
+		t.Error("Did not expect kept section contents in output")
+	}
+
+	parsed, err := ParseText(&text)
+	if err != nil {
+		t.Fatalf("ParseText failed: %v", err)
+	}
+	if _, err := parsed.Encode(); err == nil {
+		t.Error("Expected Encode to refuse unspliced sections")
+	}
+
+	parsed.Sections[1].Records[0].Text = "Hello"
+	if err := parsed.Splice(og); err != nil {
+		t.Fatalf("Splice failed: %v", err)
+	}
+	encoded, err := parsed.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	roundTrip, err := Decode(encoded)
+	if err != nil {
+		t.Fatalf("Decode of spliced file failed: %v", err)
+	}
+	if roundTrip.Sections[0].Records[0].Text != "Hi" {
+		t.Errorf("Expected kept section 0 to be restored, got %+v", roundTrip.Sections[0])
+	}
+	if roundTrip.Sections[1].Records[0].Text != "Hello" {
+		t.Errorf("Expected edited section 1, got %+v", roundTrip.Sections[1])
+	}
+	if !bytes.Equal(roundTrip.Sections[2].Records[0].Header, []byte{0x0B, 0x0C}) {
+		t.Errorf("Expected kept section 2 to be restored, got %+v", roundTrip.Sections[2])
+	}
+}
+
+func TestSpliceMissingSection(t *testing.T) {
+	f := &File{Sections: []Section{{}, {Layout: Layout{Kind: Kept}}}}
+	og := &File{Sections: []Section{{}}}
+	if err := f.Splice(og); err == nil {
+		t.Error("Expected error splicing section missing from original")
+	}
+}
+
+func TestKeptSectionRejectsContents(t *testing.T) {
+	if _, err := ParseText(strings.NewReader("SECTION 0 KEEP\n[01 02]\n")); err == nil {
+		t.Error("Expected error for data in KEEP section")
+	}
+}
@@ -0,0 +1,343 @@
+package fil
+
+/*
+ Text format (texts.txt, resource.txt)
+ - Tabs, spaces, \r, and \n separate tokens (except inside quotes, brackets and braces.)
+ - Ignore anything on a line past ';' (outside quotes)
+ - When we see [, there will be some number of hex bytes followed by ] on the same line that start a new record header
+ - When we see {, there will be named header fields followed by } on the same line that start a new record header
+   - e.g. {id=0x0102 color=3 x=40 y=120 flags=0x05}, see HeaderFields for the layout
+ - When we see '"', we parse a string that belongs to the preceding header
+   - the string ends with ", and is taken exactly as written, spaces and all
+   - a string may continue over several lines; each line break is a newline in the string
+   - \n \t \r \" \\ and \x## are supported escape sequences.
+   - if a character is missing from the charset, this is a fatal error.
+   - a string still open when a line starts with [, { or SECTION is reported as unterminated.
+ - NO_NUL after a string means the string isn't NUL-terminated (it runs to the end of the section)
+ - When we see SECTION N, a new section begins
+   - Sections are numbered 0..N and sequential. Anything else is a fatal error.
+   - SECTION N may be followed by the section's layout:
+     - RECORDS H: H header bytes before each string (the default is RECORDS 5)
+     - STRINGS: strings without headers
+     - BINARY: only [hex] blocks follow, copied verbatim
+     - KEEP: nothing follows; the section is copied from the original file
+*/
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/chadlyb/qadam/shared"
+)
+
+// ParseText reads the text form of a .FIL file.
+// Errors in the text are *PosError, giving the line and column; ParseText
+// returns the first, ParseTextAll all of them.
+func ParseText(r io.Reader) (*File, error) {
+	f, errs, err := ParseTextAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return f, nil
+}
+
+// ParseTextAll is ParseText, but carries on past errors in the text and
+// returns every one of them. The File is only usable if there are none.
+// The error is for failures reading r.
+func ParseTextAll(r io.Reader) (*File, []*PosError, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, errs := LexAll(string(src))
+	p := &parser{tokens: tokens, errs: errs}
+	p.parse()
+	if len(p.f.Sections) == 0 && len(p.errs) == 0 {
+		p.errs = append(p.errs, errorAt(Pos{}, CodeNoSections, "no sections found"))
+	}
+
+	// Lexer and parser errors are found separately; report them in file order
+	sort.SliceStable(p.errs, func(i, j int) bool {
+		a, b := p.errs[i].Pos, p.errs[j].Pos
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return &p.f, p.errs, nil
+}
+
+type parser struct {
+	tokens []Token
+	i      int
+	f      File
+	errs   []*PosError
+
+	// open is true while the last record has a header but no string yet
+	open bool
+}
+
+func (p *parser) fail(err *PosError) {
+	p.errs = append(p.errs, err)
+}
+
+func (p *parser) section() *Section {
+	return &p.f.Sections[len(p.f.Sections)-1]
+}
+
+// word returns the next token if it is one of the given (case-insensitive) words
+func (p *parser) word(words ...string) (Token, bool) {
+	if p.i >= len(p.tokens) || p.tokens[p.i].Kind != WordToken {
+		return Token{}, false
+	}
+	t := p.tokens[p.i]
+	for _, w := range words {
+		if strings.EqualFold(t.Text, w) {
+			p.i++
+			return t, true
+		}
+	}
+	return Token{}, false
+}
+
+func (p *parser) parse() {
+	for p.i < len(p.tokens) {
+		t := p.tokens[p.i]
+		if _, ok := p.word("SECTION"); ok {
+			p.parseSection(t)
+			continue
+		}
+		p.i++
+
+		if len(p.f.Sections) == 0 {
+			p.fail(errorAt(t.Pos, CodeBeforeSection, "'%v' before first SECTION", t.Text))
+			continue
+		}
+		s := p.section()
+
+		switch {
+		case s.Layout.Kind == Kept:
+			p.fail(errorAt(t.Pos, CodeKeptContents, "nothing may follow SECTION %d KEEP, got '%v'", len(p.f.Sections)-1, t.Text))
+		case t.Kind == HexToken:
+			data, err := hex.DecodeString(strings.Join(strings.Fields(t.Text), ""))
+			if err != nil {
+				p.fail(errorAt(t.Pos, CodeInvalidHex, "invalid hex: %v", err))
+			}
+			if s.Layout.Kind == Binary {
+				s.Data = append(s.Data, data...)
+			} else {
+				s.Records = append(s.Records, Record{Header: data, NoNul: true, Pos: t.Pos})
+				p.open = true
+			}
+		case s.Layout.Kind == Binary:
+			p.fail(errorAt(t.Pos, CodeBinaryContents, "only hex blocks are allowed in a BINARY section, got '%v'", t.Text))
+		case t.Kind == HeaderToken:
+			h, err := ParseHeaderFields(t.Text)
+			if err != nil {
+				p.fail(errorAt(t.Pos, CodeInvalidHeader, "%v", err))
+			}
+			s.Records = append(s.Records, Record{Header: h.Bytes(), NoNul: true, Pos: t.Pos})
+			p.open = true
+		case t.Kind == StringToken:
+			text := p.parseString(t)
+			if !p.open {
+				s.Records = append(s.Records, Record{Pos: t.Pos})
+			}
+			rec := &s.Records[len(s.Records)-1]
+			rec.Text = text
+			rec.NoNul = false
+			p.open = false
+		case t.Kind == WordToken && t.Text == "NO_NUL":
+			// "NO_NUL" token to scrub last string terminator
+			if len(s.Records) == 0 {
+				p.fail(errorAt(t.Pos, CodeStrayNoNul, "encountered NO_NUL without any record in section"))
+				continue
+			}
+			rec := &s.Records[len(s.Records)-1]
+			if p.open || rec.NoNul {
+				p.fail(errorAt(t.Pos, CodeStrayNoNul, "encountered NO_NUL but previous token wasn't a string"))
+				continue
+			}
+			rec.NoNul = true
+		default:
+			p.fail(errorAt(t.Pos, CodeUnknownToken, "unrecognized token '%v'", t.Text))
+		}
+	}
+}
+
+// parseString unescapes a string token, reporting each bad escape and
+// each character missing from the charset at its own position.
+func (p *parser) parseString(t Token) string {
+	var sb strings.Builder
+	failed := false
+	pos := Pos{t.Pos.Line, t.Pos.Column + 1}
+	runes := []rune(t.Text)
+	for i := 0; i < len(runes); i++ {
+		at := pos
+		r := runes[i]
+		if r == '\\' {
+			n := 2
+			if i+1 < len(runes) && runes[i+1] == 'x' {
+				n = 4
+			}
+			esc := string(runes[i:min(i+n, len(runes))])
+			text, err := shared.UnescapeString(esc)
+			if err != nil {
+				p.fail(errorAt(at, CodeBadEscape, "%v", err))
+				failed = true
+			}
+			sb.WriteString(text)
+			i += len([]rune(esc)) - 1
+			pos.Column += len([]rune(esc))
+			continue
+		}
+
+		if _, ok := shared.CharsetMapToByte[r]; !ok {
+			p.fail(errorAt(at, CodeMissingCharset, "character %q missing from charset", r))
+			failed = true
+		}
+		sb.WriteRune(r)
+		if r == '\n' {
+			pos = Pos{pos.Line + 1, 1}
+		} else {
+			pos.Column++
+		}
+	}
+
+	text := sb.String()
+	if !failed {
+		// Escapes can still produce bytes the charset can't encode
+		if _, err := EncodeString(text); err != nil {
+			p.fail(errorAt(t.Pos, CodeMissingCharset, "%v", err))
+		}
+	}
+	return text
+}
+
+// parseSection parses the number and optional layout after SECTION.
+// After an error, the section is still added, so the rest of the file is checked.
+func (p *parser) parseSection(t Token) {
+	defer func() { p.open = false }()
+
+	layout := DefaultLayout
+	if p.i >= len(p.tokens) || p.tokens[p.i].Kind != WordToken {
+		p.fail(errorAt(t.Pos, CodeBadSection, "SECTION missing argument"))
+		p.f.Sections = append(p.f.Sections, Section{Layout: layout, Pos: t.Pos})
+		return
+	}
+	arg := p.tokens[p.i]
+	p.i++
+	n, err := strconv.Atoi(arg.Text)
+	if err != nil {
+		p.fail(errorAt(arg.Pos, CodeBadSection, "invalid SECTION number '%v'", arg.Text))
+	} else if n != len(p.f.Sections) {
+		p.fail(errorAt(arg.Pos, CodeBadSection, "out-of-order SECTION, expected %d got %d", len(p.f.Sections), n))
+	}
+
+	if kw, ok := p.word("RECORDS", "STRINGS", "BINARY", "KEEP"); ok {
+		switch strings.ToUpper(kw.Text) {
+		case "RECORDS":
+			if p.i >= len(p.tokens) || p.tokens[p.i].Kind != WordToken {
+				p.fail(errorAt(kw.Pos, CodeBadSection, "RECORDS missing header size"))
+				break
+			}
+			size := p.tokens[p.i]
+			p.i++
+			n, err := strconv.Atoi(size.Text)
+			if err != nil || n < 1 {
+				p.fail(errorAt(size.Pos, CodeBadSection, "invalid RECORDS header size '%v'", size.Text))
+				break
+			}
+			layout = Layout{Kind: Records, HeaderSize: n}
+		case "STRINGS":
+			layout = Layout{Kind: Strings}
+		case "BINARY":
+			layout = Layout{Kind: Binary}
+		case "KEEP":
+			layout = Layout{Kind: Kept}
+		}
+	}
+
+	p.f.Sections = append(p.f.Sections, Section{Layout: layout, Pos: t.Pos})
+}
+
+// WriteText writes the text form of the file, as read by ParseText.
+func (f *File) WriteText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for i, s := range f.Sections {
+		if s.Layout.IsDefault() {
+			fmt.Fprintf(bw, "SECTION %v\n", i)
+		} else {
+			fmt.Fprintf(bw, "SECTION %v %v\n", i, s.Layout)
+		}
+		if s.Layout.Kind == Binary {
+			writeBinary(bw, s.Data)
+			continue
+		}
+		for _, r := range s.Records {
+			writeRecord(bw, r)
+		}
+	}
+	fmt.Fprintf(bw, "\n")
+	return bw.Flush()
+}
+
+// binaryLineSize is the number of bytes per line of a BINARY section
+const binaryLineSize = 16
+
+func writeBinary(w io.Writer, data []byte) {
+	for len(data) > 0 {
+		n := min(len(data), binaryLineSize)
+		fmt.Fprintf(w, "[% X]\n", data[:n])
+		data = data[n:]
+	}
+}
+
+func writeRecord(w io.Writer, r Record) {
+	if len(r.Header) == 0 && !r.HasText() {
+		return
+	}
+	if h, ok := r.Fields(); ok {
+		fmt.Fprintf(w, "%v", h)
+	} else if len(r.Header) > 0 {
+		fmt.Fprintf(w, "[% X]", r.Header)
+	}
+	if r.HasText() {
+		if len(r.Header) > 0 {
+			fmt.Fprintf(w, " ")
+		}
+		fmt.Fprintf(w, "\"%v\"", EscapeString(r.Text))
+		if r.NoNul {
+			fmt.Fprintf(w, " NO_NUL")
+		}
+	}
+	fmt.Fprintf(w, "\n")
+}
+
+// EscapeString quotes the characters that can't appear verbatim inside a text-form string.
+func EscapeString(text string) string {
+	var b strings.Builder
+	for _, ch := range text {
+		switch ch {
+		case '\n':
+			b.WriteString("\\n")
+		case '\t':
+			b.WriteString("\\t")
+		case '\r':
+			b.WriteString("\\r")
+		case '\\':
+			b.WriteString("\\\\")
+		case '"':
+			b.WriteString("\\\"")
+		default:
+			b.WriteRune(ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,131 @@
+package fil
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	f := &File{Sections: []Section{
+		{Records: []Record{
+			{Header: []byte{0x01, 0x02, 0x03, 0x04, 0x05}, Text: "Say \"hi\"\n"},
+			{Header: []byte{0x0A, 0x0B, 0x0C, 0x0D, 0x0E}, Text: "cut", NoNul: true},
+		}},
+		{Records: []Record{
+			{Header: []byte{0xFF}, NoNul: true},
+		}},
+	}}
+
+	var out bytes.Buffer
+	if err := f.WriteText(&out); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+
+	expected := "SECTION 0\n" +
+		"{id=0x0201 color=3 x=4 y=5 flags=0x00} \"Say \\\"hi\\\"\\n\"\n" +
+		"{id=0x0B0A color=12 x=13 y=14 flags=0x00} \"cut\" NO_NUL\n" +
+		"SECTION 1\n" +
+		"[FF]\n" +
+		"\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected output.\nExpected:\n%s\nGot:\n%s", expected, out.String())
+	}
+}
+
+func TestParseText(t *testing.T) {
+	input := `; comment line
+SECTION 0
+[01 02 03 04 05] "Ahoj světe" ; trailing comment
+{y=5 x=4 flags=0 color=3 id=0x0201}
+"Tab\there"
+[0102030405]
+SECTION 1
+[0A 0B 0C 0D 0E] "end" NO_NUL
+`
+
+	f, err := ParseText(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseText failed: %v", err)
+	}
+
+	if len(f.Sections) != 2 {
+		t.Fatalf("Expected 2 sections, got %d", len(f.Sections))
+	}
+
+	recs := f.Sections[0].Records
+	if len(recs) != 3 {
+		t.Fatalf("Expected 3 records, got %+v", recs)
+	}
+	if recs[0].Text != "Ahoj světe" || recs[0].NoNul {
+		t.Errorf("Unexpected first record %+v", recs[0])
+	}
+	if !bytes.Equal(recs[1].Header, []byte{1, 2, 3, 4, 5}) || recs[1].Text != "Tab\there" || recs[1].NoNul {
+		t.Errorf("Expected named header and string on the next line to form one record, got %+v", recs[1])
+	}
+	if !bytes.Equal(recs[2].Header, []byte{1, 2, 3, 4, 5}) || recs[2].HasText() {
+		t.Errorf("Expected raw hex header-only record, got %+v", recs[2])
+	}
+
+	recs = f.Sections[1].Records
+	if len(recs) != 1 || recs[0].Text != "end" || !recs[0].NoNul {
+		t.Errorf("Unexpected NO_NUL record %+v", recs)
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+	}{
+		{"No sections", "; nothing here\n"},
+		{"Out of order", "SECTION 1\n"},
+		{"Data before section", "[01] \"x\"\nSECTION 0\n"},
+		{"Bad hex", "SECTION 0\n[0G]\n"},
+		{"Unclosed hex", "SECTION 0\n[01 02\n"},
+		{"Unterminated string", "SECTION 0\n[01] \"abc\n"},
+		{"Missing charset", "SECTION 0\n[01] \"€\"\n"},
+		{"Unclosed header", "SECTION 0\n{id=1 color=2 x=3 y=4 flags=5\n"},
+		{"Bad header", "SECTION 0\n{id=1 color=2 x=3 y=4}\n"},
+		{"Stray NO_NUL", "SECTION 0\n[01] NO_NUL\n"},
+		{"Unknown token", "SECTION 0\nfoo\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseText(strings.NewReader(tc.input)); err == nil {
+				t.Errorf("Expected error parsing %q", tc.input)
+			}
+		})
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	data := buildFil(
+		[]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A, 0x00, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x00, 0x0B},
+		[]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A},
+	)
+
+	f, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	var text bytes.Buffer
+	if err := f.WriteText(&text); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+
+	parsed, err := ParseText(&text)
+	if err != nil {
+		t.Fatalf("ParseText failed: %v", err)
+	}
+
+	encoded, err := parsed.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(encoded, data) {
+		t.Errorf("Round trip mismatch.\nExpected %X\nGot      %X", data, encoded)
+	}
+}
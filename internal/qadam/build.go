@@ -0,0 +1,191 @@
+package qadam
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chadlyb/qadam/edition"
+	"github.com/chadlyb/qadam/patch"
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// buildOptions selects the optional checks of a build
+type buildOptions struct {
+	lint     bool // check texts.txt and resource.txt against the originals
+	strict   bool // fail on EXE patch lines that can't be applied, rather than skipping them
+	relocate bool // move EXE strings that are too long for their place
+	pack     bool // make room for EXE strings that are too long by packing the strings after them
+
+	trustRefs bool // move EXE strings even if what may refer to them can't all be confirmed as references
+
+	sizeFieldsIn string // the executable holding the sizes of the data files; project.DefaultSizeFieldsIn if empty
+
+	requireKnown bool // refuse originals that aren't a known edition of the game
+}
+
+// build compiles the text of files in the extracted directory srcPath into outputDir. Problems in
+// the edited files are added to diags, and the build fails if any are errors.
+func build(srcPath string, outputDir string, files []project.File, opts buildOptions, diags *shared.Diagnostics) error {
+	srcOgPath := filepath.Join(srcPath, "og")
+
+	ed, err := edition.Check(srcOgPath, opts.requireKnown, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	// Use provided output directory or default to ../built relative to source
+	if outputDir == "" {
+		outputDir = filepath.Join(srcPath, "..", "built")
+	}
+
+	err = shared.CopyCleanDir(srcOgPath, outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to copy clean directory: %w", err)
+	}
+
+	// Every file is processed even if an earlier one has problems, so they're all reported at once
+	patchOpts := patchOptions{strict: opts.strict, relocate: opts.relocate, pack: opts.pack, trustRefs: opts.trustRefs}
+	sizeExe := opts.sizeFieldsIn
+	if sizeExe == "" {
+		sizeExe = project.DefaultSizeFieldsIn
+	}
+	var sizes []sizeField
+	for _, f := range files {
+		text := filepath.Join(srcPath, f.Text)
+		og := filepath.Join(srcOgPath, f.Name)
+		out := filepath.Join(outputDir, f.Name)
+		switch f.Codec {
+		case project.CodecFIL:
+			if err := qcompile(text, og, out, opts.lint, diags); err != nil {
+				return fmt.Errorf("failed to compile %v: %w", f.Text, err)
+			}
+		case project.CodecEXE:
+			if err := qpatchStrings(og, out, text, patchOpts, diags); err != nil {
+				return fmt.Errorf("failed to patch strings in %v: %w", f.Name, err)
+			}
+		default:
+			return fmt.Errorf("unknown codec '%v' for %v", f.Codec, f.Name)
+		}
+		if f.SizeField {
+			sizes = append(sizes, sizeField{f.Name, og, out, sizeFieldOffset(ed, sizeExe, f.Name)})
+		}
+	}
+
+	if n := diags.Count(shared.SeverityError); n > 0 {
+		return fmt.Errorf("found %v error(s) and %v warning(s)", n, diags.Count(shared.SeverityWarning))
+	}
+
+	// Patch the game executable to have correct file sizes
+	var sizeOffsets []int
+	if len(sizes) > 0 {
+		sizeOffsets, err = patchFileSizes(filepath.Join(srcOgPath, sizeExe), filepath.Join(outputDir, sizeExe), sizes)
+		if err != nil {
+			return fmt.Errorf("failed to patch file sizes: %w", err)
+		}
+	}
+
+	return selfCheck(srcPath, outputDir, files, sizeExe, sizes, sizeOffsets, diags)
+}
+
+// buildPatches builds srcPath like build, then writes a patch bundle for the
+// files that changed to patchDir: IPS and BPS patches against og, and a
+// manifest with their hashes. Unless outputDir is set, the built game files
+// are only kept long enough to make the patches.
+func buildPatches(srcPath, outputDir, patchDir string, files []project.File, opts buildOptions, diags *shared.Diagnostics) error {
+	if outputDir == "" {
+		tmp, err := os.MkdirTemp("", "qadam-build-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tmp)
+		outputDir = filepath.Join(tmp, "built")
+	}
+
+	srcOgPath := filepath.Join(srcPath, "og")
+	if within(patchDir, srcOgPath) {
+		return fmt.Errorf("patch directory %v is in the originals at %v", patchDir, srcOgPath)
+	}
+	if err := build(srcPath, outputDir, files, opts, diags); err != nil {
+		return err
+	}
+
+	// Only the earlier bundle goes, in case patchDir holds anything else
+	if err := patch.RemoveBundle(patchDir); err != nil {
+		return fmt.Errorf("failed to clear patch directory: %w", err)
+	}
+	m, err := patch.WriteBundle(srcOgPath, outputDir, patchDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("INFO: Wrote patches for %v file(s) to %v\n", len(m.Files), patchDir)
+	return nil
+}
+
+// within reports whether path is dir or inside it
+func within(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// setupBuild adds the flags of build to fs
+func setupBuild(fs *flag.FlagSet) runFunc {
+	outputDir := fs.String("o", "", "Output directory (default: ../built relative to source)")
+	noLint := fs.Bool("no-lint", false, "Skip checking texts.txt and resource.txt against the originals")
+	lenient := fs.Bool("lenient", false, "Skip EXE patch lines that can't be applied with a warning, instead of failing")
+	relocate := fs.Bool("relocate", false, "Move EXE strings that are too long for their place to space freed by shortened strings")
+	pack := fs.Bool("pack", false, "Make room for EXE strings that are too long by packing the strings after them closer together")
+	trustRefs := fs.Bool("trust-refs", false, "Move EXE strings even when something that can't be confirmed as a reference may refer to them")
+	knownEdition := fs.Bool("known-edition", false, "Refuse originals that aren't a known edition of the game")
+	patchDir := fs.String("patch", "", "Write IPS and BPS patches against the originals, and their manifest, to this directory instead of a built copy of the game")
+
+	return func(args []string, diags *shared.Diagnostics) error {
+		srcPath, files, sizeFieldsIn := args[0], project.DefaultFiles, project.DefaultSizeFieldsIn
+		p, err := openProject(args[0])
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			srcPath, files, sizeFieldsIn = p.Path(p.Extracted), p.Files, p.SizeFieldsIn
+			if *patchDir == "" {
+				*patchDir = p.Path(p.Patch)
+			}
+			if *outputDir == "" && *patchDir == "" {
+				*outputDir = p.Path(p.Built)
+			}
+			*noLint = *noLint || p.Options.NoLint
+			*lenient = *lenient || p.Options.Lenient
+			*relocate = *relocate || p.Options.Relocate
+			*pack = *pack || p.Options.Pack
+			*trustRefs = *trustRefs || p.Options.TrustRefs
+			*knownEdition = *knownEdition || p.Options.KnownEdition
+		}
+
+		if *outputDir != "" {
+			fmt.Printf("INFO: Output directory: %s\n", *outputDir)
+		}
+
+		opts := buildOptions{lint: !*noLint, strict: !*lenient, relocate: *relocate, pack: *pack, trustRefs: *trustRefs, sizeFieldsIn: sizeFieldsIn, requireKnown: *knownEdition}
+		if *patchDir != "" {
+			err = buildPatches(srcPath, *outputDir, *patchDir, files, opts, diags)
+		} else {
+			err = build(srcPath, *outputDir, files, opts, diags)
+		}
+		if err == nil {
+			if n := diags.Count(shared.SeverityWarning); n > 0 {
+				fmt.Fprintf(os.Stderr, "Skipped %v line(s) that couldn't be applied.\n", n)
+			}
+		}
+		return err
+	}
+}
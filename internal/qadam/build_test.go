@@ -1,10 +1,14 @@
-package main
+package qadam
 
 import (
 	"encoding/binary"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/chadlyb/qadam/patch"
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
 )
 
 func TestGameExecutablePatching(t *testing.T) {
@@ -81,3 +85,62 @@ func TestGameExecutablePatching(t *testing.T) {
 		t.Error("Non-patch data was modified unexpectedly")
 	}
 }
+
+func TestBuildSizeFieldsIn(t *testing.T) {
+	// The executable of writeGame, called LOADER.EXE instead
+	srcPath := writeExtracted(t)
+	og := filepath.Join(srcPath, "og")
+	if err := os.Rename(filepath.Join(og, "GAME.EXE"), filepath.Join(og, "LOADER.EXE")); err != nil {
+		t.Fatal(err)
+	}
+	files := append([]project.File(nil), project.DefaultFiles...)
+	files[2].Name = "LOADER.EXE"
+
+	outputDir := filepath.Join(t.TempDir(), "built")
+	var diags shared.Diagnostics
+	opts := buildOptions{lint: true, strict: true, sizeFieldsIn: "LOADER.EXE"}
+	if err := build(srcPath, outputDir, files, opts, &diags); err != nil {
+		t.Fatalf("build failed: %v %v", err, diags)
+	}
+
+	exe, err := os.ReadFile(filepath.Join(outputDir, "LOADER.EXE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(outputDir, "TEXTS.FIL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := binary.LittleEndian.Uint32(exe[0x04:]); int64(got) != info.Size() {
+		t.Errorf("Expected the TEXTS.FIL size field in LOADER.EXE to hold %v, got %v", info.Size(), got)
+	}
+}
+
+func TestBuildPatchesKeepsOtherFiles(t *testing.T) {
+	srcPath := writeExtracted(t)
+	entries, err := os.ReadDir(srcPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The bundle goes next to the text, which must survive building it, twice
+	for range 2 {
+		var diags shared.Diagnostics
+		if err := buildPatches(srcPath, "", srcPath, project.DefaultFiles, buildOptions{lint: true, strict: true}, &diags); err != nil {
+			t.Fatalf("buildPatches failed: %v %v", err, diags)
+		}
+	}
+	for _, e := range entries {
+		if _, err := os.Stat(filepath.Join(srcPath, e.Name())); err != nil {
+			t.Errorf("Expected %v to be kept: %v", e.Name(), err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(srcPath, patch.ManifestName)); err != nil {
+		t.Errorf("Expected the manifest to be written: %v", err)
+	}
+
+	var diags shared.Diagnostics
+	if err := buildPatches(srcPath, "", filepath.Join(srcPath, "og"), project.DefaultFiles, buildOptions{lint: true, strict: true}, &diags); err == nil {
+		t.Error("Expected an error for a patch directory in the originals")
+	}
+}
@@ -0,0 +1,235 @@
+package qadam
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chadlyb/qadam/fil"
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// entry is one string of an extracted directory
+type entry struct {
+	file     string // the text file it's in
+	line     int
+	key      string // where it is in the original: "section:record" for .FIL files, the offset range for executables
+	original string // the string in the original file
+	text     string // the string as edited
+}
+
+// changed reports whether the string was edited
+func (e entry) changed() bool {
+	return e.text != e.original
+}
+
+// loadEntries returns every string of files in the extracted directory srcPath, and its original
+func loadEntries(srcPath string, files []project.File) ([]entry, error) {
+	var entries []entry
+	for _, f := range files {
+		src, err := os.ReadFile(filepath.Join(srcPath, f.Text))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read %v: %w", f.Text, err)
+		}
+		og, err := os.ReadFile(filepath.Join(srcPath, "og", f.Name))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read original %v: %w", f.Name, err)
+		}
+
+		var found []entry
+		if f.Codec == project.CodecEXE {
+			found = exeEntries(f.Text, string(src), og)
+		} else {
+			found, err = filEntries(f.Text, f.Name, src, og)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't read %v: %w", f.Text, err)
+			}
+		}
+		entries = append(entries, found...)
+	}
+	return entries, nil
+}
+
+// filEntries returns the strings of the text form src of the .FIL file named name, whose original is og.
+// Records are matched to the original by position, as lint requires they stay in place.
+func filEntries(file, name string, src, og []byte) ([]entry, error) {
+	f, err := fil.ParseText(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	ogFile, err := fil.DecodeWithLayouts(og, fil.LayoutsFor(name))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode original %v: %w", name, err)
+	}
+
+	var entries []entry
+	for i, s := range f.Sections {
+		if s.Layout.Kind == fil.Kept || s.Layout.Kind == fil.Binary {
+			continue
+		}
+		for j, r := range s.Records {
+			if !r.HasText() {
+				continue
+			}
+			e := entry{file: file, line: r.Pos.Line, key: fmt.Sprintf("%v:%v", i, j), text: r.Text}
+			if i < len(ogFile.Sections) && j < len(ogFile.Sections[i].Records) {
+				e.original = ogFile.Sections[i].Records[j].Text
+			}
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// exeEntries returns the strings of the patch file src for executable og.
+// Lines whose range doesn't hold a string of og are left out.
+func exeEntries(file, src string, og []byte) []entry {
+	var entries []entry
+	for i, line := range strings.Split(src, "\n") {
+		p, lerr := parseLine(i+1, strings.TrimSuffix(line, "\r"))
+		if lerr != nil || !p.holdsString(og) {
+			continue
+		}
+		original, prefix := p.original(og)
+		text := p.bytes
+		if bytes.HasPrefix(text, prefix) {
+			text = text[len(prefix):]
+		}
+		entries = append(entries, entry{
+			file:     file,
+			line:     i + 1,
+			key:      fmt.Sprintf("%08x-%08x", p.begin, p.end),
+			original: decodeText(original),
+			text:     decodeText(text),
+		})
+	}
+	return entries
+}
+
+// original returns the string the line replaces in data, the original file,
+// and the non-text bytes before it. The line must be within data.
+func (p *patchLine) original(data []byte) (text, prefix []byte) {
+	if p.pascal {
+		return data[p.begin+1 : p.end], nil
+	}
+	og := data[p.begin : p.end-1]
+	n := shared.GarbagePrefixLen(og)
+	return og[n:], og[:n]
+}
+
+// holdsString reports whether the line's range holds a string of data, the original file.
+// The string may still be too long, or otherwise not fit to apply.
+func (p *patchLine) holdsString(data []byte) bool {
+	lerr := p.check(data)
+	return lerr == nil || lerr.code != codeBadRange && lerr.code != codeNoTerminator && lerr.code != codeBadLength
+}
+
+// decodeText converts charset bytes of an executable to text
+func decodeText(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		switch b {
+		case '\n', '\t':
+			runes[i] = rune(b)
+		default:
+			runes[i] = shared.CharsetRunes[b]
+		}
+	}
+	return string(runes)
+}
+
+// diff writes the strings of files in srcPath that differ from the original to w, and returns how many there are
+func diff(srcPath string, files []project.File, w io.Writer) (int, error) {
+	entries, err := loadEntries(srcPath, files)
+	if err != nil {
+		return 0, err
+	}
+	bw := bufio.NewWriter(w)
+	n := 0
+	for _, e := range entries {
+		if !e.changed() {
+			continue
+		}
+		n++
+		fmt.Fprintf(bw, "%v:%v: %v\n", e.file, e.line, e.key)
+		fmt.Fprintf(bw, "  - \"%v\"\n", fil.EscapeString(e.original))
+		fmt.Fprintf(bw, "  + \"%v\"\n", fil.EscapeString(e.text))
+	}
+	return n, bw.Flush()
+}
+
+// setupDiff adds the flags of diff to fs
+func setupDiff(fs *flag.FlagSet) runFunc {
+	return func(args []string, diags *shared.Diagnostics) error {
+		srcPath, files, err := extractedDir(args[0])
+		if err != nil {
+			return err
+		}
+		n, err := diff(srcPath, files, os.Stdout)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("INFO: %v string(s) differ from the original\n", n)
+		return nil
+	}
+}
+
+// fileStats counts the strings of a text file
+type fileStats struct {
+	file           string
+	total, changed int
+}
+
+// stats counts the strings of the text file of each of files in srcPath, and the strings of them all, last
+func stats(srcPath string, files []project.File) ([]fileStats, error) {
+	entries, err := loadEntries(srcPath, files)
+	if err != nil {
+		return nil, err
+	}
+	all := fileStats{file: "total"}
+	var counts []fileStats
+	for _, f := range files {
+		s := fileStats{file: f.Text}
+		for _, e := range entries {
+			if e.file != f.Text {
+				continue
+			}
+			s.total++
+			if e.changed() {
+				s.changed++
+			}
+		}
+		all.total += s.total
+		all.changed += s.changed
+		counts = append(counts, s)
+	}
+	return append(counts, all), nil
+}
+
+// setupStats adds the flags of stats to fs
+func setupStats(fs *flag.FlagSet) runFunc {
+	return func(args []string, diags *shared.Diagnostics) error {
+		srcPath, files, err := extractedDir(args[0])
+		if err != nil {
+			return err
+		}
+		counts, err := stats(srcPath, files)
+		if err != nil {
+			return err
+		}
+		for _, s := range counts {
+			percent := 100.0
+			if s.total > 0 {
+				percent = 100 * float64(s.changed) / float64(s.total)
+			}
+			fmt.Printf("%-16v %5v of %5v strings changed (%.0f%%)\n", s.file+":", s.changed, s.total, percent)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,116 @@
+package qadam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadlyb/qadam/project"
+)
+
+// writeGame writes the files of a tiny fake game to dir: data files holding
+// "Hi" and "Hi!", and executables holding a string, with GAME.EXE also
+// holding the sizes of the data files.
+func writeGame(t *testing.T, dir string) {
+	t.Helper()
+	// "Hi" in charset: H=0x48, i=0x69, !=0x21; obfuscated with +0x31: 0x79, 0x9A, 0x52
+	texts := []byte{0x01, 0x07, 0x00, 0x00, 0x0F, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A, 0x00}
+	resource := []byte{0x01, 0x07, 0x00, 0x00, 0x10, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A, 0x52, 0x00}
+
+	exe := make([]byte, 0x20)
+	binary.LittleEndian.PutUint32(exe[0x04:], uint32(len(texts)))
+	binary.LittleEndian.PutUint32(exe[0x0C:], uint32(len(resource)))
+	exe = append(exe, "\x00Welcome to the game\x00"...)
+
+	files := map[string][]byte{
+		"TEXTS.FIL":    texts,
+		"RESOURCE.FIL": resource,
+		"GAME.EXE":     exe,
+		"INSTALL.EXE":  []byte("\x00\x00Install the game\x00"),
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatalf("Failed to write %v: %v", name, err)
+		}
+	}
+}
+
+// writeExtracted writes an extracted directory of the game of writeGame to a
+// temporary directory and returns it, with texts.txt's string translated
+func writeExtracted(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeGame(t, filepath.Join(dir, "og"))
+	files := map[string]string{
+		"texts.txt":       "SECTION 0\n; the greeting\n[01 02 03 04 05] \"Ahoj\"\n",
+		"resource.txt":    "SECTION 0\n[01 02 03 04 05] \"Hi!\"\n",
+		"game_exe.txt":    "00000021-00000035: \"Welcome to the game\" ; the title\n",
+		"install_exe.txt": "00000002-00000013: \"Install the game\"\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write %v: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestLoadEntries(t *testing.T) {
+	entries, err := loadEntries(writeExtracted(t), project.DefaultFiles)
+	if err != nil {
+		t.Fatalf("loadEntries failed: %v", err)
+	}
+	want := []entry{
+		{"texts.txt", 3, "0:0", "Hi", "Ahoj"},
+		{"resource.txt", 2, "0:0", "Hi!", "Hi!"},
+		{"game_exe.txt", 1, "00000021-00000035", "Welcome to the game", "Welcome to the game"},
+		{"install_exe.txt", 1, "00000002-00000013", "Install the game", "Install the game"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %v entries, got %+v", len(want), entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], entries[i])
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	var out bytes.Buffer
+	n, err := diff(writeExtracted(t), project.DefaultFiles, &out)
+	if err != nil {
+		t.Fatalf("diff failed: %v", err)
+	}
+	want := "texts.txt:3: 0:0\n  - \"Hi\"\n  + \"Ahoj\"\n"
+	if n != 1 || out.String() != want {
+		t.Errorf("Expected 1 difference %q, got %v: %q", want, n, out.String())
+	}
+}
+
+func TestStats(t *testing.T) {
+	files, err := stats(writeExtracted(t), project.DefaultFiles)
+	if err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+	want := []fileStats{
+		{"texts.txt", 1, 1},
+		{"resource.txt", 1, 0},
+		{"game_exe.txt", 1, 0},
+		{"install_exe.txt", 1, 0},
+		{"total", 4, 1},
+	}
+	if len(files) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, files)
+	}
+	for i := range want {
+		if files[i] != want[i] {
+			t.Errorf("Expected %v, got %v", want[i], files[i])
+		}
+	}
+}
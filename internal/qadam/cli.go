@@ -0,0 +1,256 @@
+// Package qadam implements the qadam command and its subcommands. The
+// extract, build and lint tools are the same commands, run on their own.
+package qadam
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// Exit codes of every command
+const (
+	ExitOK     = 0 // the command succeeded
+	ExitFailed = 1 // the command failed, or found problems
+	ExitUsage  = 2 // the command line was wrong
+)
+
+// globals are the flags every command takes, before or after its name
+type globals struct {
+	verbose  bool
+	jsonPath string
+	version  bool
+}
+
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.BoolVar(&g.verbose, "v", g.verbose, "Enable verbose debug output")
+	fs.StringVar(&g.jsonPath, "json", g.jsonPath, "Also write diagnostics as JSON to this file ('-' for stdout)")
+	fs.BoolVar(&g.version, "version", g.version, "Show version information")
+}
+
+// runFunc runs a command on its arguments, adding any problems found to diags
+type runFunc func(args []string, diags *shared.Diagnostics) error
+
+// command is a subcommand of qadam
+type command struct {
+	name    string
+	args    []string // what the arguments are, for the usage line
+	summary string
+	title   string // of the command when it's a tool of its own
+	noun    string // what the command does, for messages such as "Build failed!"
+
+	// setup adds the command's flags to fs, and returns the function that runs it
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+var commands = []*command{
+	{"extract", []string{"<original source directory or project>"}, "Extract the game's text into files to translate",
+		"QADAM Extract Tool", "Extraction", setupExtract},
+	{"build", []string{"<extracted directory or project>"}, "Build the translated game from an extracted directory",
+		"QADAM Build Tool", "Build", setupBuild},
+	{"verify", []string{"<original source directory or project>"}, "Check that decoding and building each file of the game unchanged gives back the original",
+		"QADAM Verify Tool", "Verification", setupVerify},
+	{"diff", []string{"<extracted directory or project>"}, "List the strings that differ from the original",
+		"QADAM Diff Tool", "Diff", setupDiff},
+	{"stats", []string{"<extracted directory or project>"}, "Count the strings translated in each file",
+		"QADAM Stats Tool", "Stats", setupStats},
+	{"export", []string{"<extracted directory or project>", "<file.csv>"}, "Write every string and its translation to a CSV file",
+		"QADAM Export Tool", "Export", setupExport},
+	{"import", []string{"<extracted directory or project>", "<file.csv>"}, "Read translations back from a CSV file written by export",
+		"QADAM Import Tool", "Import", setupImport},
+	{"lint", []string{"<extracted directory or project>"}, "Check the edited text files against the originals",
+		"QADAM Lint Tool", "Lint", setupLint},
+}
+
+// findCommand returns the command called name, or nil
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// guessCommand returns the command to run on a folder dragged onto qadam:
+// build for an extracted directory, extract for the game itself. For a
+// project, it's build once the project has been extracted.
+func guessCommand(dir string) *command {
+	exists := func(path string) bool {
+		_, err := os.Stat(filepath.Join(dir, path))
+		return err == nil
+	}
+	if manifest, ok := project.Find(dir); ok {
+		p, err := project.Load(manifest)
+		if err == nil {
+			dir = p.Path(p.Extracted)
+		}
+		if err != nil || !exists("og") {
+			return findCommand("extract")
+		}
+		return findCommand("build")
+	}
+	switch {
+	case exists(filepath.Join("og", "GAME.EXE")):
+		return findCommand("build")
+	case exists("GAME.EXE"):
+		return findCommand("extract")
+	}
+	return nil
+}
+
+// usage describes qadam and its commands
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: qadam [-v] [-json <file>] <command> [flags] <arguments>\n")
+	fmt.Fprintf(w, "       qadam <game, extracted directory or project>\n")
+	fmt.Fprintf(w, "       qadam -version\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8v %v\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun 'qadam help <command>' for the flags of a command.\n")
+	fmt.Fprintf(w, "Given only a directory, qadam extracts it if it holds the game, or builds it if it was extracted.\n")
+	fmt.Fprintf(w, "A project is a %v manifest, or a directory holding one; see the README.\n", project.ManifestName)
+	fmt.Fprintf(w, "Exit status is %v on success, %v on failure, and %v for a wrong command line.\n", ExitOK, ExitFailed, ExitUsage)
+}
+
+// usage describes the command, run as prog
+func (c *command) usage(w io.Writer, prog string, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %v [flags] %v\n\n%v.\n\nFlags:\n", prog, strings.Join(c.args, " "), c.summary)
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
+
+// Run runs qadam with the command line args, without the program name, and returns the exit code
+func Run(version string, args []string) int {
+	var g globals
+	fs := flag.NewFlagSet("qadam", flag.ContinueOnError)
+	g.register(fs)
+	fs.Usage = func() { usage(fs.Output()) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+	if g.version {
+		fmt.Printf("QADAM v%s\n", version)
+		return ExitOK
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		usage(os.Stderr)
+		shared.PauseIfNeeded("Drag the game folder, or the extracted folder, onto this program.")
+		return ExitUsage
+	}
+
+	if args[0] == "help" {
+		if len(args) == 1 {
+			usage(os.Stdout)
+			return ExitOK
+		}
+		c := findCommand(args[1])
+		if c == nil {
+			fmt.Fprintf(os.Stderr, "Error: unknown command '%v'\n", args[1])
+			return ExitUsage
+		}
+		fs := flag.NewFlagSet("qadam "+c.name, flag.ContinueOnError)
+		g.register(fs)
+		c.setup(fs)
+		c.usage(os.Stdout, "qadam "+c.name, fs)
+		return ExitOK
+	}
+
+	if c := findCommand(args[0]); c != nil {
+		return c.run("qadam "+c.name, version, &g, args[1:])
+	}
+	if len(args) == 1 {
+		if c := guessCommand(args[0]); c != nil {
+			return c.run("qadam "+c.name, version, &g, args)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Error: unknown command '%v'\n\n", args[0])
+	usage(os.Stderr)
+	shared.PauseIfNeeded("Drag the game folder, or the extracted folder, onto this program.")
+	return ExitUsage
+}
+
+// Main runs the command called name as a tool of its own, with the command
+// line args, without the program name, and returns the exit code
+func Main(name, version string, args []string) int {
+	c := findCommand(name)
+	if c == nil {
+		panic("qadam: no command " + name)
+	}
+	return c.run(os.Args[0], version, &globals{}, args)
+}
+
+// run runs the command with args, after its name, reports what it found, and returns the exit code.
+// prog is how the command was run, for usage. If run from Explorer, it pauses before returning.
+func (c *command) run(prog, version string, g *globals, args []string) int {
+	fs := flag.NewFlagSet(prog, flag.ContinueOnError)
+	g.register(fs)
+	run := c.setup(fs)
+	fs.Usage = func() { c.usage(fs.Output(), prog, fs) }
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	if g.version {
+		fmt.Printf("%v v%s\n", c.title, version)
+		return ExitOK
+	}
+
+	if fs.NArg() != len(c.args) {
+		if fs.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "Error: expected %v argument(s), got %v\n\n", len(c.args), fs.NArg())
+		}
+		c.usage(os.Stderr, prog, fs)
+		shared.PauseIfNeeded(fmt.Sprintf("Drag the %v onto this program.", strings.Trim(c.args[0], "<>")))
+		return ExitUsage
+	}
+
+	// Set debug mode globally if requested
+	debugMode = g.verbose
+	if debugMode {
+		fmt.Println("DEBUG: Verbose mode enabled")
+	}
+
+	var diags shared.Diagnostics
+	err := run(fs.Args(), &diags)
+	if reportErr := reportDiagnostics(diags, g.jsonPath); reportErr != nil && err == nil {
+		err = reportErr
+	}
+	if err == nil && diags.HasErrors() {
+		err = fmt.Errorf("found %v problem(s)", diags.Count(shared.SeverityError))
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		shared.PauseIfNeeded(c.noun + " failed! Press Enter to continue...")
+		return ExitFailed
+	}
+
+	// Pause if running from Explorer so the window doesn't close immediately
+	shared.PauseIfNeeded(c.noun + " succeeded! Press Enter to continue...")
+	return ExitOK
+}
+
+// reportDiagnostics prints diags to stderr, and also writes them as JSON to jsonPath if it's set
+func reportDiagnostics(diags shared.Diagnostics, jsonPath string) error {
+	diags.Print(os.Stderr)
+	if jsonPath == "" {
+		return nil
+	}
+	return diags.WriteJSONFile(jsonPath)
+}
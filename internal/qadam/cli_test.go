@@ -0,0 +1,62 @@
+package qadam
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunExitCodes(t *testing.T) {
+	dir := writeExtracted(t)
+	testCases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"No arguments", nil, ExitUsage},
+		{"Unknown command", []string{"frobnicate", dir}, ExitUsage},
+		{"Unknown flag", []string{"stats", "-frobnicate", dir}, ExitUsage},
+		{"Too many arguments", []string{"stats", dir, dir}, ExitUsage},
+		{"Help", []string{"help", "build"}, ExitOK},
+		{"Version", []string{"-version"}, ExitOK},
+		{"Stats", []string{"stats", dir}, ExitOK},
+		{"Global flag after the command", []string{"lint", "-json", filepath.Join(dir, "lint.json"), dir}, ExitOK},
+		{"Missing directory", []string{"stats", filepath.Join(dir, "missing")}, ExitFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Run("test", tc.args); got != tc.want {
+				t.Errorf("Expected exit code %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "lint.json")); err != nil {
+		t.Errorf("Expected lint to write diagnostics: %v", err)
+	}
+}
+
+func TestRunFailsOnProblems(t *testing.T) {
+	dir := writeExtracted(t)
+	if err := os.WriteFile(filepath.Join(dir, "texts.txt"), []byte("SECTION 0\n[01 02 03 04 06] \"Hi\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := Run("test", []string{"lint", dir}); got != ExitFailed {
+		t.Errorf("Expected exit code %v for a changed header, got %v", ExitFailed, got)
+	}
+}
+
+func TestGuessCommand(t *testing.T) {
+	game := t.TempDir()
+	writeGame(t, game)
+	if c := guessCommand(game); c == nil || c.name != "extract" {
+		t.Errorf("Expected extract for the game, got %+v", c)
+	}
+	if c := guessCommand(writeExtracted(t)); c == nil || c.name != "build" {
+		t.Errorf("Expected build for an extracted directory, got %+v", c)
+	}
+	if c := guessCommand(t.TempDir()); c != nil {
+		t.Errorf("Expected nothing for an empty directory, got %+v", c)
+	}
+}
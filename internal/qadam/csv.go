@@ -0,0 +1,331 @@
+package qadam
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chadlyb/qadam/fil"
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// csvHeader is the first row of an exported CSV file
+var csvHeader = []string{"file", "key", "original", "translation"}
+
+// exportCSV writes every string of files in srcPath to w as CSV, one row per string.
+// The translation column is empty for strings that haven't been changed.
+func exportCSV(srcPath string, files []project.File, w io.Writer) (int, error) {
+	entries, err := loadEntries(srcPath, files)
+	if err != nil {
+		return 0, err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, e := range entries {
+		translation := ""
+		if e.changed() {
+			translation = e.text
+		}
+		cw.Write([]string{e.file, e.key, e.original, translation})
+	}
+	cw.Flush()
+	return len(entries), cw.Error()
+}
+
+// translation is a row of an imported CSV file
+type translation struct {
+	row      int
+	original string
+	text     string
+	used     bool
+}
+
+// rowError is a problem with a row of an imported CSV file
+type rowError struct {
+	row int
+	err error
+}
+
+// readCSV reads the translations of the CSV file r, by file and key.
+// Rows with an empty translation are left out.
+func readCSV(r io.Reader) (map[string]map[string]*translation, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the header: %w", err)
+	}
+	if !slices.Equal(header, csvHeader) {
+		return nil, fmt.Errorf("expected the columns %v, got %v", strings.Join(csvHeader, ","), strings.Join(header, ","))
+	}
+
+	files := map[string]map[string]*translation{}
+	for row := 2; ; row++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[3] == "" {
+			continue
+		}
+		if files[record[0]] == nil {
+			files[record[0]] = map[string]*translation{}
+		}
+		files[record[0]][record[1]] = &translation{row: row, original: record[2], text: record[3]}
+	}
+}
+
+// importCSV reads the translations of the CSV file r, as written by exportCSV,
+// into the text files of files in srcPath, and returns how many strings changed.
+// Comments and layout of the text files are kept; only the strings are replaced.
+func importCSV(srcPath string, files []project.File, r io.Reader) (int, error) {
+	rows, err := readCSV(r)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := loadEntries(srcPath, files)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []rowError
+	changed := map[string]map[string]string{}
+	for _, e := range entries {
+		t := rows[e.file][e.key]
+		if t == nil {
+			continue
+		}
+		t.used = true
+		if t.original != e.original {
+			errs = append(errs, rowError{t.row, fmt.Errorf("the original of %v %v is \"%v\", not \"%v\"; was the CSV exported from another game?",
+				e.file, e.key, fil.EscapeString(e.original), fil.EscapeString(t.original))})
+			continue
+		}
+		if _, err := fil.EncodeString(t.text); err != nil {
+			errs = append(errs, rowError{t.row, err})
+			continue
+		}
+		if t.text == e.text {
+			continue
+		}
+		if changed[e.file] == nil {
+			changed[e.file] = map[string]string{}
+		}
+		changed[e.file][e.key] = t.text
+	}
+	for file, keys := range rows {
+		for key, t := range keys {
+			if !t.used {
+				errs = append(errs, rowError{t.row, fmt.Errorf("no string %v in %v", key, file)})
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].row < errs[j].row })
+		joined := make([]error, len(errs))
+		for i, e := range errs {
+			joined[i] = fmt.Errorf("row %v: %w", e.row, e.err)
+		}
+		return 0, errors.Join(joined...)
+	}
+
+	n := 0
+	for _, f := range files {
+		texts := changed[f.Text]
+		if len(texts) == 0 {
+			continue
+		}
+		path := filepath.Join(srcPath, f.Text)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return n, fmt.Errorf("couldn't read %v: %w", f.Text, err)
+		}
+		var out string
+		if f.Codec == project.CodecEXE {
+			out = replaceEXEStrings(string(src), texts, nil)
+		} else {
+			out, err = replaceFILStrings(string(src), texts, nil)
+			if err != nil {
+				return n, fmt.Errorf("couldn't update %v: %w", f.Text, err)
+			}
+		}
+		if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+			return n, fmt.Errorf("couldn't write %v: %w", f.Text, err)
+		}
+		n += len(texts)
+	}
+	return n, nil
+}
+
+// replaceEXEStrings replaces the strings of the patch file src with texts, by key.
+// Notes, by key, are written as a comment on a line of their own before the line.
+func replaceEXEStrings(src string, texts, notes map[string]string) string {
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		p, lerr := parseLine(i+1, strings.TrimSuffix(line, "\r"))
+		if lerr != nil {
+			continue
+		}
+		key := fmt.Sprintf("%08x-%08x", p.begin, p.end)
+		if text, ok := texts[key]; ok {
+			m := lineRegex.FindStringSubmatchIndex(strings.TrimSuffix(line, "\r"))
+			begin, end := m[2*stringGroup], m[2*stringGroup+1]
+			lines[i] = line[:begin] + fil.EscapeString(text) + line[end:]
+		}
+		if note, ok := notes[key]; ok {
+			eol := ""
+			if strings.HasSuffix(line, "\r") {
+				eol = "\r"
+			}
+			lines[i] = "; " + note + eol + "\n" + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// replaceFILStrings replaces the strings of the text form src of a .FIL file with texts, by key.
+// Notes, by key, are written as a comment on a line of their own before the record.
+func replaceFILStrings(src string, texts, notes map[string]string) (string, error) {
+	bom := ""
+	if strings.HasPrefix(src, "\uFEFF") {
+		bom, src = "\uFEFF", strings.TrimPrefix(src, "\uFEFF")
+	}
+	f, err := fil.ParseText(strings.NewReader(src))
+	if err != nil {
+		return "", err
+	}
+	tokens, err := fil.Lex(src)
+	if err != nil {
+		return "", err
+	}
+
+	// A record's string is the first string token at or after where the record starts
+	type span struct {
+		begin, end int
+		text       string
+	}
+	var spans []span
+	t := 0
+	for i, s := range f.Sections {
+		for j, r := range s.Records {
+			key := fmt.Sprintf("%v:%v", i, j)
+			if note, ok := notes[key]; ok {
+				at := offsetOf(src, fil.Pos{Line: r.Pos.Line, Column: 1})
+				spans = append(spans, span{at, at, "; " + note + "\n"})
+			}
+			text, ok := texts[key]
+			if !ok || !r.HasText() {
+				continue
+			}
+			for t < len(tokens) && (tokens[t].Kind != fil.StringToken || before(tokens[t].Pos, r.Pos)) {
+				t++
+			}
+			if t == len(tokens) {
+				return "", fmt.Errorf("couldn't find the string of record %v", key)
+			}
+			begin := offsetOf(src, tokens[t].Pos)
+			spans = append(spans, span{begin, stringEnd(src, begin), fmt.Sprintf("\"%v\"", fil.EscapeString(text))})
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(bom)
+	at := 0
+	for _, s := range spans {
+		b.WriteString(src[at:s.begin])
+		b.WriteString(s.text)
+		at = s.end
+	}
+	b.WriteString(src[at:])
+	return b.String(), nil
+}
+
+// before reports whether a comes before b
+func before(a, b fil.Pos) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// offsetOf returns the byte offset of pos in src
+func offsetOf(src string, pos fil.Pos) int {
+	at := 0
+	for line := 1; line < pos.Line; line++ {
+		i := strings.IndexByte(src[at:], '\n')
+		if i < 0 {
+			return len(src)
+		}
+		at += i + 1
+	}
+	for col := 1; col < pos.Column && at < len(src); col++ {
+		_, size := utf8.DecodeRuneInString(src[at:])
+		at += size
+	}
+	return at
+}
+
+// stringEnd returns the offset just past the closing quote of the string whose opening quote is at src[begin]
+func stringEnd(src string, begin int) int {
+	for i := begin + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(src)
+}
+
+// setupExport adds the flags of export to fs
+func setupExport(fs *flag.FlagSet) runFunc {
+	return func(args []string, diags *shared.Diagnostics) error {
+		srcPath, files, err := extractedDir(args[0])
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to create file '%v': %w", args[1], err)
+		}
+		defer out.Close()
+
+		n, err := exportCSV(srcPath, files, out)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("INFO: Exported %v string(s) to %v\n", n, args[1])
+		return out.Close()
+	}
+}
+
+// setupImport adds the flags of import to fs
+func setupImport(fs *flag.FlagSet) runFunc {
+	return func(args []string, diags *shared.Diagnostics) error {
+		srcPath, files, err := extractedDir(args[0])
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to open file '%v': %w", args[1], err)
+		}
+		defer in.Close()
+
+		n, err := importCSV(srcPath, files, in)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("INFO: Imported %v changed string(s) from %v\n", n, args[1])
+		return nil
+	}
+}
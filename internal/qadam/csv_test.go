@@ -0,0 +1,91 @@
+package qadam
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/project"
+)
+
+func TestExportCSV(t *testing.T) {
+	var out bytes.Buffer
+	n, err := exportCSV(writeExtracted(t), project.DefaultFiles, &out)
+	if err != nil {
+		t.Fatalf("exportCSV failed: %v", err)
+	}
+	want := "file,key,original,translation\n" +
+		"texts.txt,0:0,Hi,Ahoj\n" +
+		"resource.txt,0:0,Hi!,\n" +
+		"game_exe.txt,00000021-00000035,Welcome to the game,\n" +
+		"install_exe.txt,00000002-00000013,Install the game,\n"
+	if n != 4 || out.String() != want {
+		t.Errorf("Expected 4 strings:\n%v\ngot %v:\n%v", want, n, out.String())
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	dir := writeExtracted(t)
+	csv := "file,key,original,translation\n" +
+		"texts.txt,0:0,Hi,Ahoj\n" +
+		"resource.txt,0:0,Hi!,\"Ahoj,\nsvete!\"\n" +
+		"game_exe.txt,00000021-00000035,Welcome to the game,\"Vitej \"\"doma\"\"\"\n" +
+		"install_exe.txt,00000002-00000013,Install the game,\n"
+	n, err := importCSV(dir, project.DefaultFiles, strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("importCSV failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 changed strings, got %v", n)
+	}
+
+	// Only the strings change; comments stay
+	want := map[string]string{
+		"texts.txt":       "SECTION 0\n; the greeting\n[01 02 03 04 05] \"Ahoj\"\n",
+		"resource.txt":    "SECTION 0\n[01 02 03 04 05] \"Ahoj,\\nsvete!\"\n",
+		"game_exe.txt":    "00000021-00000035: \"Vitej \\\"doma\\\"\" ; the title\n",
+		"install_exe.txt": "00000002-00000013: \"Install the game\"\n",
+	}
+	for name, text := range want {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != text {
+			t.Errorf("Expected %v to be %q, got %q", name, text, got)
+		}
+	}
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		csv     string
+		wantErr string
+	}{
+		{"Wrong columns", "file,key,text\n", "expected the columns"},
+		{"Unknown key", "file,key,original,translation\ntexts.txt,0:7,Hi,Ahoj\n", "row 2: no string 0:7 in texts.txt"},
+		{"Other original", "file,key,original,translation\ntexts.txt,0:0,Hello,Ahoj\n", "row 2: the original of texts.txt 0:0 is"},
+		{"Missing from charset", "file,key,original,translation\ntexts.txt,0:0,Hi,Ahoj €\n", "row 2:"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeExtracted(t)
+			_, err := importCSV(dir, project.DefaultFiles, strings.NewReader(tc.csv))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+			// Nothing is written if any row is wrong
+			got, err := os.ReadFile(filepath.Join(dir, "texts.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(got), "\"Ahoj\"") {
+				t.Errorf("Expected texts.txt to be unchanged, got %q", got)
+			}
+		})
+	}
+}
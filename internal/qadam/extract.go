@@ -0,0 +1,147 @@
+package qadam
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/chadlyb/qadam/edition"
+	"github.com/chadlyb/qadam/fil"
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// Global debug flag
+var debugMode = false
+
+// extractOptions selects how extract finds text
+type extractOptions struct {
+	allStrings   bool // extract all strings of the executables, not just the likely ones
+	requireKnown bool // refuse originals that aren't a known edition of the game
+	merge        bool // carry the translations of an earlier extract in the output directory over
+}
+
+// extract extracts the text of files in the game at srcPath to outputDir,
+// along with a copy of the game in outputDir/og for build
+func extract(srcPath string, outputDir string, files []project.File, opts extractOptions, diags *shared.Diagnostics) error {
+	ed, err := edition.Check(srcPath, opts.requireKnown, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if ed != nil {
+		if err := checkSections(srcPath, ed); err != nil {
+			return err
+		}
+	}
+
+	// Use provided output directory or default to ../extracted relative to source
+	if outputDir == "" {
+		outputDir = filepath.Join(srcPath, "..", "extracted")
+	}
+
+	// Read the translations before the originals they're of are replaced
+	var old map[string][]translated
+	if opts.merge {
+		if old, err = loadTranslations(outputDir, files); err != nil {
+			return err
+		}
+		if err := keepOld(outputDir, files); err != nil {
+			return err
+		}
+	}
+
+	destOgPath := filepath.Join(outputDir, "og")
+
+	err = shared.CopyCleanDir(srcPath, destOgPath)
+	if err != nil {
+		return fmt.Errorf("couldn't copy clean directory: %w", err)
+	}
+
+	for _, f := range files {
+		src := filepath.Join(srcPath, f.Name)
+		dest := filepath.Join(outputDir, f.Text)
+		switch f.Codec {
+		case project.CodecFIL:
+			if err := qdecomp(src, dest); err != nil {
+				return fmt.Errorf("couldn't decompile %v: %w", f.Name, err)
+			}
+		case project.CodecEXE:
+			if err := qgetStrings(src, dest, opts.allStrings, stringRange(ed, f.Name)); err != nil {
+				return fmt.Errorf("couldn't get strings from %v: %w", f.Name, err)
+			}
+		default:
+			return fmt.Errorf("unknown codec '%v' for %v", f.Codec, f.Name)
+		}
+	}
+
+	if opts.merge {
+		return merge(outputDir, files, old, diags)
+	}
+	return nil
+}
+
+// stringRange returns where to look for text in the named executable of edition ed, if it's known
+func stringRange(ed *edition.Edition, name string) edition.Range {
+	if ed == nil {
+		return edition.Range{}
+	}
+	return ed.StringRanges[name]
+}
+
+// checkSections checks the data files in srcPath have as many sections as edition ed says
+func checkSections(srcPath string, ed *edition.Edition) error {
+	for name, want := range ed.Sections {
+		data, err := os.ReadFile(filepath.Join(srcPath, name))
+		if err != nil {
+			return fmt.Errorf("couldn't read %v: %w", name, err)
+		}
+		f, err := fil.Decode(data)
+		if err != nil {
+			return fmt.Errorf("couldn't decode %v: %w", name, err)
+		}
+		if len(f.Sections) != want {
+			return fmt.Errorf("%v has %v sections, but %v has %v", name, len(f.Sections), ed.Name, want)
+		}
+	}
+	return nil
+}
+
+// setupExtract adds the flags of extract to fs
+func setupExtract(fs *flag.FlagSet) runFunc {
+	allStrings := fs.Bool("all-strings", false, "Extract all strings (non-conservative mode)")
+	outputDir := fs.String("o", "", "Output directory (default: ../extracted relative to source)")
+	knownEdition := fs.Bool("known-edition", false, "Refuse files that aren't a known edition of the game")
+	merge := fs.Bool("merge", false, "Keep the translations of an earlier extract in the output directory")
+
+	return func(args []string, diags *shared.Diagnostics) error {
+		srcPath, files := args[0], project.DefaultFiles
+		p, err := openProject(args[0])
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			if p.Game == "" {
+				return errors.New("the project doesn't say where the game is")
+			}
+			srcPath, files = p.Path(p.Game), p.Files
+			if *outputDir == "" {
+				*outputDir = p.Path(p.Extracted)
+			}
+			*allStrings = *allStrings || p.Options.AllStrings
+			*knownEdition = *knownEdition || p.Options.KnownEdition
+		}
+
+		if *allStrings {
+			fmt.Println("INFO: All-strings mode enabled (non-conservative extraction)")
+		}
+		if *outputDir != "" {
+			fmt.Printf("INFO: Output directory: %s\n", *outputDir)
+		}
+		if *merge {
+			fmt.Println("INFO: Merging the translations of the earlier extract")
+		}
+		return extract(srcPath, *outputDir, files, extractOptions{*allStrings, *knownEdition, *merge}, diags)
+	}
+}
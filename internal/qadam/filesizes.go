@@ -0,0 +1,159 @@
+package qadam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chadlyb/qadam/edition"
+)
+
+// sizeFieldWindow is how far from a file's name a size field may be, to tell it apart from other
+// occurrences of the same value
+const sizeFieldWindow = 0x100
+
+// sizeField is a data file whose size the game executable keeps, and checks when it opens it
+type sizeField struct {
+	name    string // as the game refers to it, e.g. TEXTS.FIL
+	ogPath  string // the original file, whose size the executable holds
+	newPath string // the built file, whose size it's to hold
+	offset  int    // where the field is in the executable, if the edition is known; 0 to search for it
+}
+
+// sizeFieldOffset returns the offset of the named file's size field in the executable exeName of
+// edition ed, or 0 if it isn't known. Editions only know the fields in GAME.EXE.
+func sizeFieldOffset(ed *edition.Edition, exeName, name string) int {
+	if ed == nil || !strings.EqualFold(exeName, "GAME.EXE") {
+		return 0
+	}
+	return ed.SizeFields[name]
+}
+
+// findSizeField returns the offset of the 32-bit field holding size in exe, the original executable exeName.
+// If size is found more than once, the one field near the file's name is taken; it fails if
+// there isn't exactly one.
+func findSizeField(exe []byte, exeName, name string, size uint32) (int, error) {
+	var value [4]byte
+	binary.LittleEndian.PutUint32(value[:], size)
+
+	var found []int
+	for at := 0; ; at++ {
+		i := bytes.Index(exe[at:], value[:])
+		if i < 0 {
+			break
+		}
+		at += i
+		found = append(found, at)
+	}
+	if len(found) == 0 {
+		return 0, fmt.Errorf("size of %v (%v bytes) not found in %v; are they from different releases of the game?", name, size, exeName)
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+
+	// Several places hold the size; take the one near the name
+	upper := bytes.ToUpper(exe)
+	var names []int
+	for at := 0; ; at++ {
+		i := bytes.Index(upper[at:], []byte(strings.ToUpper(name)))
+		if i < 0 {
+			break
+		}
+		at += i
+		names = append(names, at)
+	}
+	var near []int
+	for _, f := range found {
+		for _, n := range names {
+			if f >= n-sizeFieldWindow && f <= n+sizeFieldWindow {
+				near = append(near, f)
+				break
+			}
+		}
+	}
+	if len(near) != 1 {
+		return 0, fmt.Errorf("size of %v (%v bytes) is ambiguous in %v: found at %v, of which %v near the name %v",
+			name, size, exeName, offsetList(found), len(near), name)
+	}
+	return near[0], nil
+}
+
+// offsetList formats file offsets as e.g. "0x1A6E6, 0x1A706"
+func offsetList(offsets []int) string {
+	s := make([]string, len(offsets))
+	for i, o := range offsets {
+		s[i] = fmt.Sprintf("0x%X", o)
+	}
+	return strings.Join(s, ", ")
+}
+
+// patchFileSizes writes the sizes of the built data files to the executable at gameExePath.
+// The fields are found in the original executable at ogExePath by the original files' sizes, unless
+// the edition gives their offsets, and must still hold them in the built one; nothing is written
+// unless every field is found. It returns the offsets of the fields.
+func patchFileSizes(ogExePath, gameExePath string, fields []sizeField) ([]int, error) {
+	ogExe, err := os.ReadFile(ogExePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original game executable: %w", err)
+	}
+	gameExeData, err := os.ReadFile(gameExePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read game executable: %w", err)
+	}
+	if len(gameExeData) != len(ogExe) {
+		return nil, fmt.Errorf("game executable is %v bytes, but the original is %v", len(gameExeData), len(ogExe))
+	}
+
+	exeName := filepath.Base(gameExePath)
+	offsets := make([]int, len(fields))
+	sizes := make([]uint32, len(fields))
+	for i, f := range fields {
+		ogInfo, err := os.Lstat(f.ogPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get original %v size: %w", f.name, err)
+		}
+		newInfo, err := os.Lstat(f.newPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %v size: %w", f.name, err)
+		}
+
+		if f.offset > 0 {
+			offsets[i] = f.offset
+			if f.offset+4 > len(ogExe) || binary.LittleEndian.Uint32(ogExe[f.offset:]) != uint32(ogInfo.Size()) {
+				return nil, fmt.Errorf("%v size field of this edition, at 0x%X, doesn't hold the original size %v", f.name, f.offset, ogInfo.Size())
+			}
+		} else {
+			offsets[i], err = findSizeField(ogExe, exeName, f.name, uint32(ogInfo.Size()))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if got := binary.LittleEndian.Uint32(gameExeData[offsets[i]:]); got != uint32(ogInfo.Size()) {
+			return nil, fmt.Errorf("%v size field at 0x%X holds %v in the built %v, not the original %v; was it patched over?",
+				f.name, offsets[i], got, exeName, ogInfo.Size())
+		}
+		for j := range i {
+			if offsets[j] == offsets[i] {
+				return nil, fmt.Errorf("%v and %v size fields were both found at 0x%X", fields[j].name, f.name, offsets[i])
+			}
+		}
+		sizes[i] = uint32(newInfo.Size())
+	}
+
+	// Patch the values in-place
+	for i := range fields {
+		binary.LittleEndian.PutUint32(gameExeData[offsets[i]:], sizes[i])
+	}
+
+	// Write the patched data back to the file
+	err = os.WriteFile(gameExePath, gameExeData, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write patched game executable: %w", err)
+	}
+
+	return offsets, nil
+}
@@ -0,0 +1,107 @@
+package qadam
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exeWithSizes returns a fake GAME.EXE holding the given 32-bit values at the given offsets
+func exeWithSizes(size int, values map[int]uint32) []byte {
+	data := make([]byte, size)
+	for at, v := range values {
+		binary.LittleEndian.PutUint32(data[at:], v)
+	}
+	return data
+}
+
+func TestFindSizeField(t *testing.T) {
+	testCases := []struct {
+		name    string
+		exe     []byte
+		want    int
+		wantErr string
+	}{
+		{"Unique", exeWithSizes(0x400, map[int]uint32{0x206: 0x1234}), 0x206, ""},
+		{"Absent", exeWithSizes(0x400, map[int]uint32{0x206: 0x1235}), 0, "not found"},
+		{"Near the name", func() []byte {
+			data := exeWithSizes(0x400, map[int]uint32{0x10: 0x1234, 0x306: 0x1234})
+			copy(data[0x2F0:], "texts.fil")
+			return data
+		}(), 0x306, ""},
+		{"Ambiguous", exeWithSizes(0x400, map[int]uint32{0x10: 0x1234, 0x306: 0x1234}), 0, "ambiguous"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := findSizeField(tc.exe, "GAME.EXE", "TEXTS.FIL", 0x1234)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findSizeField failed: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Expected 0x%X, got 0x%X", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPatchFileSizes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write %v: %v", name, err)
+		}
+		return path
+	}
+
+	exe := exeWithSizes(0x400, map[int]uint32{0x106: 10, 0x1E6: 20})
+	ogExe := writeFile("OG.EXE", exe)
+	gameExe := writeFile("GAME.EXE", exe)
+	fields := []sizeField{
+		{"TEXTS.FIL", writeFile("og_texts", make([]byte, 10)), writeFile("texts", make([]byte, 15)), 0},
+		{"RESOURCE.FIL", writeFile("og_resource", make([]byte, 20)), writeFile("resource", make([]byte, 25)), 0x1E6},
+	}
+
+	if _, err := patchFileSizes(ogExe, gameExe, fields); err != nil {
+		t.Fatalf("patchFileSizes failed: %v", err)
+	}
+	patched, err := os.ReadFile(gameExe)
+	if err != nil {
+		t.Fatalf("Failed to read patched file: %v", err)
+	}
+	if binary.LittleEndian.Uint32(patched[0x106:]) != 15 || binary.LittleEndian.Uint32(patched[0x1E6:]) != 25 {
+		t.Errorf("Expected sizes 15 and 25, got % X and % X", patched[0x106:0x10A], patched[0x1E6:0x1EA])
+	}
+
+	// The built GAME.EXE no longer holds the original sizes, so patching again must fail without writing
+	if _, err := patchFileSizes(ogExe, gameExe, fields); err == nil || !strings.Contains(err.Error(), "patched over") {
+		t.Errorf("Expected an error about the field not holding the original size, got %v", err)
+	}
+}
+
+func TestPatchFileSizesWrongEdition(t *testing.T) {
+	dir := t.TempDir()
+	exePath := filepath.Join(dir, "GAME.EXE")
+	ogPath := filepath.Join(dir, "TEXTS.FIL")
+	if err := os.WriteFile(exePath, exeWithSizes(0x400, map[int]uint32{0x106: 10}), 0644); err != nil {
+		t.Fatalf("Failed to write GAME.EXE: %v", err)
+	}
+	if err := os.WriteFile(ogPath, make([]byte, 10), 0644); err != nil {
+		t.Fatalf("Failed to write TEXTS.FIL: %v", err)
+	}
+
+	// The edition says the field is somewhere it isn't
+	_, err := patchFileSizes(exePath, exePath, []sizeField{{"TEXTS.FIL", ogPath, ogPath, 0x200}})
+	if err == nil || !strings.Contains(err.Error(), "doesn't hold the original size") {
+		t.Errorf("Expected an error about the edition's field, got %v", err)
+	}
+}
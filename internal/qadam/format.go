@@ -0,0 +1,92 @@
+package qadam
+
+import (
+	"fmt"
+	"strings"
+)
+
+// printf conversion characters, and the flags and length modifiers that may come before them
+const (
+	formatConversions = "diouxXeEfgGcsnp"
+	formatFlags       = "-+ #0"
+	formatLengths     = "hlLFN"
+)
+
+// formatSpecifiers returns the printf format specifiers in text, as what they
+// take from the arguments: the conversion, its length modifier, and a * for
+// each width or precision passed as an argument. Flags and fixed widths are
+// left out, as changing them doesn't change the arguments read. %% isn't a
+// specifier, and neither is a % that doesn't start a valid one. Nor is one
+// with a space flag but no width or precision, as that's far more likely
+// prose, like "100% sure", than a specifier.
+func formatSpecifiers(text []byte) []string {
+	var specs []string
+	for i := 0; i < len(text); i++ {
+		if text[i] != '%' {
+			continue
+		}
+		j := i + 1
+		if j < len(text) && text[j] == '%' {
+			i = j
+			continue
+		}
+
+		var spec strings.Builder
+		space, sized := false, false
+		for j < len(text) && strings.IndexByte(formatFlags, text[j]) >= 0 {
+			space = space || text[j] == ' '
+			j++
+		}
+		// Width, then precision
+		for part := 0; part < 2; part++ {
+			if part == 1 {
+				if j >= len(text) || text[j] != '.' {
+					break
+				}
+				sized = true
+				j++
+			}
+			if j < len(text) && text[j] == '*' {
+				spec.WriteByte('*')
+				sized = true
+				j++
+			}
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				sized = true
+				j++
+			}
+		}
+		for j < len(text) && strings.IndexByte(formatLengths, text[j]) >= 0 {
+			spec.WriteByte(text[j])
+			j++
+		}
+		if j < len(text) && strings.IndexByte(formatConversions, text[j]) >= 0 && (sized || !space) {
+			spec.WriteByte(text[j])
+			specs = append(specs, "%"+spec.String())
+			i = j
+		}
+	}
+	return specs
+}
+
+// checkFormat rejects the line if text, its string, doesn't have the same printf
+// format specifiers, in the same order, as og, the original string. The game
+// passes some strings to printf, and reads its arguments by the specifiers.
+func (p *patchLine) checkFormat(og, text []byte) *lineError {
+	want := formatSpecifiers(og)
+	got := formatSpecifiers(text)
+	if strings.Join(want, " ") == strings.Join(got, " ") {
+		return nil
+	}
+	return &lineError{codeFormatChanged, p.stringCol,
+		fmt.Errorf("format specifiers differ from the original's: %v, not %v", describeSpecifiers(got), describeSpecifiers(want)),
+		fmt.Sprintf("use exactly %v in this order, as the game fills them in; write %%%% for a percent sign", describeSpecifiers(want))}
+}
+
+// describeSpecifiers lists specifiers for a message
+func describeSpecifiers(specs []string) string {
+	if len(specs) == 0 {
+		return "none"
+	}
+	return strings.Join(specs, ", ")
+}
@@ -0,0 +1,69 @@
+package qadam
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/shared"
+)
+
+func TestFormatSpecifiers(t *testing.T) {
+	testCases := []struct {
+		text string
+		want []string
+	}{
+		{"No specifiers", nil},
+		{"Score: %d of %s", []string{"%d", "%s"}},
+		{"%-10s|%5.2f|%ld|%c", []string{"%s", "%f", "%ld", "%c"}},
+		{"%*d and %.*s", []string{"%*d", "%*s"}},
+		{"100%% done", nil},
+		{"50% off", nil},
+		{"100% sure", nil},
+		{"% 5d and % .2f", []string{"%d", "%f"}},
+		{"Trailing %", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			if got := formatSpecifiers([]byte(tc.text)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestQPatchStringsFromReaderFormat(t *testing.T) {
+	srcData := []byte("Disk %c: %d bytes free\x00\x0bSaved in %s\x00I am 100% sure\x00")
+	testCases := []struct {
+		name  string
+		patch string
+		ok    bool
+	}{
+		{"Same specifiers", "00000000-00000017: \"Disk %c: volno %d B\"", true},
+		{"Width changed", "00000000-00000017: \"Disk %c: %8d B\"", true},
+		{"Reordered", "00000000-00000017: \"%d B na disku %c\"", false},
+		{"Dropped", "00000000-00000017: \"Disk %c je plny\"", false},
+		{"Added", "00000000-00000017: \"Disk %c: %d %s\"", false},
+		{"Pascal", "00000017-00000023: pascal \"Ulozeno %d\"", false},
+		{"Percent sign", "00000024-00000033: \"100 % jiste\"", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var diags shared.Diagnostics
+			var destWriter bytes.Buffer
+			err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(tc.patch), "game_exe.txt", patchOptions{strict: true}, &diags)
+			if err != nil {
+				t.Fatalf("qpatchStringsFromReader failed: %v", err)
+			}
+			if tc.ok && len(diags) != 0 {
+				t.Errorf("Expected no diagnostics, got %v", diags)
+			}
+			if !tc.ok && (len(diags) != 1 || diags[0].Code != codeFormatChanged) {
+				t.Errorf("Expected a format-changed error, got %v", diags)
+			}
+		})
+	}
+}
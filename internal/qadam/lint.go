@@ -0,0 +1,43 @@
+package qadam
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/chadlyb/qadam/fil"
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// lint checks the edited text files of the .FIL files among files in srcPath against the
+// originals in srcPath/og, adding every problem to diags.
+func lint(srcPath string, files []project.File, diags *shared.Diagnostics) error {
+	for _, f := range files {
+		if f.Codec != project.CodecFIL {
+			continue
+		}
+		err := fil.LintFile(filepath.Join(srcPath, f.Text), filepath.Join(srcPath, "og", f.Name), diags)
+		if err != nil {
+			return fmt.Errorf("couldn't lint %v: %w", f.Text, err)
+		}
+	}
+	return nil
+}
+
+// setupLint adds the flags of lint to fs
+func setupLint(fs *flag.FlagSet) runFunc {
+	return func(args []string, diags *shared.Diagnostics) error {
+		srcPath, files, err := extractedDir(args[0])
+		if err != nil {
+			return err
+		}
+		if err := lint(srcPath, files, diags); err != nil {
+			return err
+		}
+		if diags.HasErrors() {
+			return fmt.Errorf("found %v problem(s); only string contents may differ from the original", len(*diags))
+		}
+		return nil
+	}
+}
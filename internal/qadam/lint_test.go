@@ -0,0 +1,61 @@
+package qadam
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+func TestLint(t *testing.T) {
+	// "Hi" in charset: H=0x48, i=0x69; obfuscated with +0x31: 0x79, 0x9A
+	fil := []byte{
+		0x01,             // 1 entry
+		0x07, 0x00, 0x00, // offset 1: 7 (start of data section)
+		0x0F, 0x00, 0x00, // offset 2: 15 (end of data)
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A, 0x00,
+	}
+
+	srcPath := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(srcPath, "og"), 0755); err != nil {
+		t.Fatalf("Failed to create og directory: %v", err)
+	}
+	writeFile := func(name string, data string) {
+		if err := os.WriteFile(filepath.Join(srcPath, name), []byte(data), 0644); err != nil {
+			t.Fatalf("Failed to write %v: %v", name, err)
+		}
+	}
+	writeFile("og/TEXTS.FIL", string(fil))
+	writeFile("og/RESOURCE.FIL", string(fil))
+	writeFile("texts.txt", "SECTION 0\n[01 02 03 04 05] \"Ahoj\"\n")
+	writeFile("resource.txt", "SECTION 0\n[01 02 03 04 05] \"Hi\"\n")
+
+	var diags shared.Diagnostics
+	if err := lint(srcPath, project.DefaultFiles, &diags); err != nil {
+		t.Fatalf("lint failed: %v", err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("Expected no problems, got %v", diags)
+	}
+
+	writeFile("resource.txt", "SECTION 0\n[01 02 03 04 06] \"Hi\"\n")
+	diags = nil
+	if err := lint(srcPath, project.DefaultFiles, &diags); err != nil {
+		t.Fatalf("lint failed: %v", err)
+	}
+	if len(diags) != 1 || diags[0].Code != "header-changed" || diags[0].File != "resource.txt" || diags[0].Line != 2 {
+		t.Errorf("Expected 1 problem for changed header, got %v", diags)
+	}
+
+	// Parse errors are reported too, all of them
+	writeFile("texts.txt", "SECTION 0\n[01 02 03 04 05] \"€\"\n[01 02 03 04 05] \"\\q\"\n")
+	diags = nil
+	if err := lint(srcPath, project.DefaultFiles, &diags); err != nil {
+		t.Fatalf("lint failed: %v", err)
+	}
+	if len(diags) != 3 || diags[0].File != "texts.txt" || diags[1].File != "texts.txt" {
+		t.Errorf("Expected 2 parse errors in texts.txt and 1 mismatch, got %v", diags)
+	}
+}
@@ -0,0 +1,302 @@
+package qadam
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/chadlyb/qadam/fil"
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// Codes of the problems found merging translations into a new extract
+const (
+	codeStale   = "stale"
+	codeDropped = "dropped"
+)
+
+// oldSuffix is added to the names of the text files a merge replaces
+const oldSuffix = ".old"
+
+// translated is a string of an extracted directory that differs from its original,
+// kept to carry over into a new extract
+type translated struct {
+	line     int    // in the old text file
+	key      string // as in entry
+	section  int    // of a .FIL record
+	index    int    // of a .FIL record within its section
+	header   []byte // of a .FIL record
+	original string
+	text     string
+}
+
+// loadTranslations returns the translated strings of each of files in the extracted directory srcPath,
+// by text file. Files that weren't extracted yet have none.
+func loadTranslations(srcPath string, files []project.File) (map[string][]translated, error) {
+	if _, err := os.Stat(filepath.Join(srcPath, "og")); err != nil {
+		return nil, fmt.Errorf("nothing to merge: '%v' holds no earlier extract", srcPath)
+	}
+
+	found := map[string][]translated{}
+	for _, f := range files {
+		src, err := os.ReadFile(filepath.Join(srcPath, f.Text))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read %v: %w", f.Text, err)
+		}
+		og, err := os.ReadFile(filepath.Join(srcPath, "og", f.Name))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read original %v: %w", f.Name, err)
+		}
+
+		if f.Codec == project.CodecEXE {
+			for _, e := range exeEntries(f.Text, string(src), og) {
+				if e.changed() {
+					found[f.Text] = append(found[f.Text], translated{line: e.line, key: e.key, original: e.original, text: e.text})
+				}
+			}
+			continue
+		}
+		ts, err := filTranslations(f.Name, src, og)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read the translations in %v: %w; fix it before merging", f.Text, err)
+		}
+		found[f.Text] = ts
+	}
+	return found, nil
+}
+
+// filTranslations returns the records of the text form src of the .FIL file named name whose
+// strings differ from those of og
+func filTranslations(name string, src, og []byte) ([]translated, error) {
+	f, err := fil.ParseText(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	ogFile, err := fil.DecodeWithLayouts(og, fil.LayoutsFor(name))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode original %v: %w", name, err)
+	}
+
+	var ts []translated
+	for i, s := range f.Sections {
+		if s.Layout.Kind == fil.Kept || s.Layout.Kind == fil.Binary {
+			continue
+		}
+		for j, r := range s.Records {
+			original := ""
+			if i < len(ogFile.Sections) && j < len(ogFile.Sections[i].Records) {
+				original = ogFile.Sections[i].Records[j].Text
+			}
+			if !r.HasText() || r.Text == original {
+				continue
+			}
+			ts = append(ts, translated{line: r.Pos.Line, key: fmt.Sprintf("%v:%v", i, j), section: i, index: j,
+				header: r.Header, original: original, text: r.Text})
+		}
+	}
+	return ts, nil
+}
+
+// keepOld renames the text files of files in srcPath, adding oldSuffix, so a merge doesn't lose them
+func keepOld(srcPath string, files []project.File) error {
+	for _, f := range files {
+		path := filepath.Join(srcPath, f.Text)
+		if err := os.Rename(path, path+oldSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("couldn't keep the old %v: %w", f.Text, err)
+		}
+	}
+	return nil
+}
+
+// merge carries the translations old, from an earlier extract, over into the text files of files
+// freshly extracted to srcPath. Translations whose original changed are marked with a STALE
+// comment; those that match nothing are dropped. Both are reported to diags as warnings.
+func merge(srcPath string, files []project.File, old map[string][]translated, diags *shared.Diagnostics) error {
+	for _, f := range files {
+		ts := old[f.Text]
+		if len(ts) == 0 {
+			continue
+		}
+		path := filepath.Join(srcPath, f.Text)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("couldn't read %v: %w", f.Text, err)
+		}
+		og, err := os.ReadFile(filepath.Join(srcPath, "og", f.Name))
+		if err != nil {
+			return fmt.Errorf("couldn't read original %v: %w", f.Name, err)
+		}
+
+		var out string
+		var n int
+		if f.Codec == project.CodecEXE {
+			out, n = mergeEXE(f.Text, string(src), og, ts, diags)
+		} else {
+			out, n, err = mergeFIL(f.Text, f.Name, src, og, ts, diags)
+			if err != nil {
+				return fmt.Errorf("couldn't merge %v: %w", f.Text, err)
+			}
+		}
+		if err := os.WriteFile(path, []byte(out), 0644); err != nil {
+			return fmt.Errorf("couldn't write %v: %w", f.Text, err)
+		}
+		fmt.Printf("INFO: Carried over %v of %v translation(s) in %v\n", n, len(ts), f.Text)
+	}
+	return nil
+}
+
+// dropped reports that translation t, of the text file named file, matches nothing in the new extract
+func dropped(file string, t translated, diags *shared.Diagnostics) {
+	diags.Add(shared.Diagnostic{
+		File:     file,
+		Severity: shared.SeverityWarning,
+		Code:     codeDropped,
+		Message: fmt.Sprintf("the translation \"%v\" of \"%v\" (%v, line %v before) matches no string of the new extract, and was dropped",
+			fil.EscapeString(t.text), fil.EscapeString(t.original), t.key, t.line),
+		Fix: fmt.Sprintf("translate the string again where it now is; the old translation is in %v", file+oldSuffix),
+	})
+}
+
+// staleString is a string of a new extract that was given a translation of a different original
+type staleString struct {
+	line          int // in the new text file, before STALE comments were added
+	before, after string
+}
+
+// reportStale warns about each of stale, in the text file named file
+func reportStale(file string, stale []staleString, diags *shared.Diagnostics) {
+	// Each STALE comment moves the lines after it down a line
+	sort.Slice(stale, func(i, j int) bool { return stale[i].line < stale[j].line })
+	for k, s := range stale {
+		diags.Add(shared.Diagnostic{
+			File:     file,
+			Line:     s.line + k + 1,
+			Severity: shared.SeverityWarning,
+			Code:     codeStale,
+			Message: fmt.Sprintf("the original of this string changed from \"%v\" to \"%v\"; the translation may be out of date",
+				fil.EscapeString(s.before), fil.EscapeString(s.after)),
+			Fix: "check the translation against the new original, then remove the STALE comment",
+		})
+	}
+}
+
+// matchRecord finds the record of section s that translation t is for: the one at the same
+// position, if it has the same header, or else the only one with that header
+func matchRecord(s fil.Section, t translated) (int, bool) {
+	if t.index < len(s.Records) && s.Records[t.index].HasText() && bytes.Equal(s.Records[t.index].Header, t.header) {
+		return t.index, true
+	}
+	match := -1
+	for j, r := range s.Records {
+		if !r.HasText() || !bytes.Equal(r.Header, t.header) {
+			continue
+		}
+		if match >= 0 {
+			return 0, false
+		}
+		match = j
+	}
+	return match, match >= 0
+}
+
+// mergeFIL carries translations ts over into the text form src of the .FIL file named name,
+// whose original is og, and returns the new text and how many were carried over
+func mergeFIL(file, name string, src, og []byte, ts []translated, diags *shared.Diagnostics) (string, int, error) {
+	f, err := fil.ParseText(bytes.NewReader(src))
+	if err != nil {
+		return "", 0, err
+	}
+	ogFile, err := fil.DecodeWithLayouts(og, fil.LayoutsFor(name))
+	if err != nil {
+		return "", 0, fmt.Errorf("couldn't decode original %v: %w", name, err)
+	}
+
+	texts := map[string]string{}
+	notes := map[string]string{}
+	var stale []staleString
+	for _, t := range ts {
+		if t.section >= len(f.Sections) || t.section >= len(ogFile.Sections) {
+			dropped(file, t, diags)
+			continue
+		}
+		j, ok := matchRecord(f.Sections[t.section], t)
+		key := fmt.Sprintf("%v:%v", t.section, j)
+		if _, taken := texts[key]; !ok || taken || j >= len(ogFile.Sections[t.section].Records) {
+			dropped(file, t, diags)
+			continue
+		}
+		texts[key] = t.text
+
+		if original := ogFile.Sections[t.section].Records[j].Text; original != t.original {
+			notes[key] = fmt.Sprintf("STALE: the original was \"%v\"", fil.EscapeString(t.original))
+			stale = append(stale, staleString{f.Sections[t.section].Records[j].Pos.Line, t.original, original})
+		}
+	}
+
+	out, err := replaceFILStrings(string(src), texts, notes)
+	if err != nil {
+		return "", 0, err
+	}
+
+	reportStale(file, stale, diags)
+	return out, len(texts), nil
+}
+
+// mergeEXE carries translations ts over into the patch file src of executable og, and returns
+// the new text and how many were carried over. A translation goes to the line with the same
+// range, if its original is the same, or else to the first unused line with the same original.
+// Failing both, it goes to the line with the same range, marked with a STALE comment.
+func mergeEXE(file, src string, og []byte, ts []translated, diags *shared.Diagnostics) (string, int) {
+	entries := exeEntries(file, src, og)
+	texts := map[string]string{}
+	var unmatched []translated
+	for _, t := range ts {
+		key := ""
+		for _, e := range entries {
+			if e.original != t.original {
+				continue
+			}
+			if _, taken := texts[e.key]; taken {
+				continue
+			}
+			if e.key == t.key {
+				key = e.key
+				break
+			}
+			if key == "" {
+				key = e.key
+			}
+		}
+		if key == "" {
+			unmatched = append(unmatched, t)
+			continue
+		}
+		texts[key] = t.text
+	}
+
+	// Only once every translation whose original is still there has its line are the rest
+	// matched by range alone, so they don't take the line of one of those
+	notes := map[string]string{}
+	var stale []staleString
+	for _, t := range unmatched {
+		i := slices.IndexFunc(entries, func(e entry) bool { return e.key == t.key })
+		if _, taken := texts[t.key]; i < 0 || taken {
+			dropped(file, t, diags)
+			continue
+		}
+		texts[t.key] = t.text
+		notes[t.key] = fmt.Sprintf("STALE: the original was \"%v\"", fil.EscapeString(t.original))
+		stale = append(stale, staleString{entries[i].line, t.original, entries[i].original})
+	}
+	reportStale(file, stale, diags)
+	return replaceEXEStrings(src, texts, notes), len(texts)
+}
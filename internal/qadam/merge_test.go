@@ -0,0 +1,122 @@
+package qadam
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+func TestExtractMerge(t *testing.T) {
+	game := t.TempDir()
+	writeGame(t, game)
+
+	// The earlier extract was of a game whose TEXTS.FIL said "Hi!" and whose installer
+	// was for the demo, and also set it up, with the greeting, the title and the
+	// installer's strings translated
+	extracted := writeExtracted(t)
+	resource, err := os.ReadFile(filepath.Join(game, "RESOURCE.FIL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := map[string]string{
+		"og/TEXTS.FIL":    string(resource),
+		"og/INSTALL.EXE":  "\x00\x00Install the demo\x00Setup\x00",
+		"game_exe.txt":    "00000021-00000035: \"Vitejte ve hre\" ; the title\n",
+		"install_exe.txt": "00000002-00000013: \"Instaluj demo\"\n00000013-00000019: \"Nastav\"\n",
+	}
+	for name, data := range old {
+		if err := os.WriteFile(filepath.Join(extracted, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var diags shared.Diagnostics
+	if err := extract(game, extracted, project.DefaultFiles, extractOptions{allStrings: true, merge: true}, &diags); err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(extracted, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// staleLine returns the line of the text file name holding translation, which must be marked
+	// stale for the original before
+	staleLine := func(name, translation, before string) int {
+		t.Helper()
+		text := read(name)
+		lines := strings.Split(text, "\n")
+		for i, line := range lines {
+			if strings.Contains(line, "\""+translation+"\"") {
+				if i == 0 || lines[i-1] != "; STALE: the original was \""+before+"\"" {
+					t.Errorf("Expected %q marked stale in %v, got:\n%v", translation, name, text)
+				}
+				return i + 1
+			}
+		}
+		t.Errorf("Expected %q in %v, got:\n%v", translation, name, text)
+		return -1
+	}
+
+	// The greeting and the installer's title are kept, but marked stale, since their originals changed
+	stale := staleLine("texts.txt", "Ahoj", "Hi!")
+	staleInstall := staleLine("install_exe.txt", "Instaluj demo", "Install the demo")
+
+	if got := read("game_exe.txt"); !strings.Contains(got, "\"Vitejte ve hre\"") {
+		t.Errorf("Expected the title translated, got %q", got)
+	}
+	if got := read("install_exe.txt"); strings.Contains(got, "Nastav") {
+		t.Errorf("Expected the translation of a string that's gone dropped, got %q", got)
+	}
+	if got := read("install_exe.txt" + oldSuffix); got != old["install_exe.txt"] {
+		t.Errorf("Expected the old installer strings kept, got %q", got)
+	}
+
+	want := []shared.Diagnostic{
+		{File: "texts.txt", Line: stale, Code: codeStale},
+		{File: "install_exe.txt", Code: codeDropped},
+		{File: "install_exe.txt", Line: staleInstall, Code: codeStale},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("Expected %v diagnostics, got %v", len(want), diags)
+	}
+	for i, w := range want {
+		d := diags[i]
+		if d.File != w.File || d.Line != w.Line || d.Code != w.Code || d.Severity != shared.SeverityWarning {
+			t.Errorf("Expected a %v warning in %v:%v, got %v", w.Code, w.File, w.Line, d)
+		}
+	}
+}
+
+func TestExtractMergeErrors(t *testing.T) {
+	game := t.TempDir()
+	writeGame(t, game)
+
+	var diags shared.Diagnostics
+	err := extract(game, t.TempDir(), project.DefaultFiles, extractOptions{merge: true}, &diags)
+	if err == nil || !strings.Contains(err.Error(), "nothing to merge") {
+		t.Errorf("Expected nothing to merge, got %v", err)
+	}
+
+	// A text file that doesn't parse is left alone
+	extracted := writeExtracted(t)
+	broken := "SECTION 0\n[01 02 03 04 05] \"Ahoj\n"
+	if err := os.WriteFile(filepath.Join(extracted, "texts.txt"), []byte(broken), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = extract(game, extracted, project.DefaultFiles, extractOptions{merge: true}, &diags)
+	if err == nil || !strings.Contains(err.Error(), "texts.txt") {
+		t.Errorf("Expected an error naming texts.txt, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(extracted, "texts.txt")); string(data) != broken {
+		t.Errorf("Expected texts.txt left alone, got %q", data)
+	}
+}
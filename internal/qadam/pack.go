@@ -0,0 +1,120 @@
+package qadam
+
+import "github.com/chadlyb/qadam/mz"
+
+// moved is a string that packing moved
+type moved struct {
+	line     *patchLine
+	from, to uint16 // offsets in the data segment
+	refs     []mz.Reference
+}
+
+// pack makes room for strings that are too long by sharing out the space of
+// runs of back-to-back strings: strings are laid out one after the other
+// from the start of the run, and the code that refers to them is changed to
+// match. Strings that can't be moved stay where they are and split the run.
+//
+// Packed lines are written, and no longer too long; the space left at the
+// end of each packed stretch is free for relocate.
+func (r *relocator) pack(lines []*patchLine) []moved {
+	var report []moved
+	for start := 0; start < len(lines); {
+		if lines[start].pascal {
+			// Pascal strings aren't packed, and split runs
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(lines) && lines[end].begin == lines[end-1].end && !lines[end].pascal {
+			end++
+		}
+		report = append(report, r.packRun(lines[start:end])...)
+		start = end
+	}
+	return report
+}
+
+// packRun packs a run of back-to-back strings
+func (r *relocator) packRun(run []*patchLine) []moved {
+	// Strings that can't move split the run into stretches that are packed separately;
+	// the first string of a stretch stays put
+	var report []moved
+	for start := 0; start < len(run); {
+		end := start + 1
+		for end < len(run) && r.canPack(run[end]) {
+			end++
+		}
+		capacity := int(run[end-1].end)
+		if end < len(run) {
+			capacity = int(run[end].begin)
+		}
+		report = append(report, r.packStretch(run[start:end], capacity)...)
+		start = end
+	}
+	return report
+}
+
+// canPack reports whether p's string can be moved within its run
+func (r *relocator) canPack(p *patchLine) bool {
+	refs, _ := r.movable(p)
+	return refs != nil && !r.isReferenced(span{int(p.begin) + 1, int(p.end)})
+}
+
+// packStretch lays out strings one after another from the first one's place, up to limit
+func (r *relocator) packStretch(stretch []*patchLine, limit int) []moved {
+	first := stretch[0]
+	if r.isReferenced(span{int(first.begin) + len(first.bytes) + 1, int(first.end)}) {
+		// Something refers into what the first string no longer uses, so the rest start after it
+		if len(stretch) == 1 {
+			return nil
+		}
+		return r.packStretch(stretch[1:], limit)
+	}
+
+	needed := false
+	size := 0
+	for _, p := range stretch {
+		needed = needed || p.tooLong
+		size += len(p.bytes) + 1
+	}
+	if !needed || int(stretch[0].begin)+size > limit {
+		return nil
+	}
+
+	// Every reference is taken before any is put back, since new and old offsets can coincide
+	type placement struct {
+		p        *patchLine
+		at       int
+		refs     []mz.Reference
+		from, to uint16
+	}
+	var placements []placement
+	at := int(stretch[0].begin)
+	for _, p := range stretch {
+		from, _ := r.exe.SegOff(r.ds, int(p.begin))
+		to, _ := r.exe.SegOff(r.ds, at)
+		placements = append(placements, placement{p, at, nil, from, to})
+		at += len(p.bytes) + 1
+	}
+
+	clear(r.data[stretch[0].begin:limit])
+	for i, pl := range placements {
+		placements[i].refs = r.takeRefs(pl.from)
+	}
+	for _, pl := range placements {
+		r.putRefs(pl.refs, pl.to)
+	}
+	var report []moved
+	for _, pl := range placements {
+		copy(r.data[pl.at:], pl.p.bytes)
+		r.pointRefs(pl.refs, pl.to)
+		pl.p.tooLong = false
+		pl.p.packed = true
+		if pl.from != pl.to {
+			report = append(report, moved{pl.p, pl.from, pl.to, pl.refs})
+		}
+	}
+
+	r.reclaim(span{at, limit})
+	return report
+}
@@ -0,0 +1,40 @@
+package qadam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/shared"
+)
+
+func TestQPatchStringsFromReaderPackReferencedTail(t *testing.T) {
+	// Data segment: "Save Game" at 0001:0100, whose "Game" at 0001:0105 is referred to, and "No" at 0001:010A
+	srcData := buildExe([]byte{
+		0xB8, 0x05, 0x01, // mov ax, 0105 ("Game")
+		0xB8, 0x0A, 0x01, // mov ax, 010A ("No")
+	}, "Save Game\x00No\x00")
+	patchData := "00000130-0000013a: \"Ulo\"\n0000013a-0000013d: \"Nikoliv\"\n"
+
+	var diags shared.Diagnostics
+	var destWriter bytes.Buffer
+	err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", patchOptions{strict: true, pack: true}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+
+	// "Nikoliv" can't take the end of "Save Game", which is still referred to
+	if len(diags) != 1 || diags[0].Line != 2 || diags[0].Code != codeTooLong {
+		t.Fatalf("Expected a too-long error on line 2, got %v", diags)
+	}
+	out := destWriter.Bytes()
+	if string(out[0x13A:0x13D]) != "No\x00" {
+		t.Errorf("Expected \"No\" to be unchanged, got %q", out[0x13A:0x13D])
+	}
+	for at, want := range map[int]uint16{0x24: 0x0105, 0x27: 0x010A} {
+		if got := binary.LittleEndian.Uint16(out[at:]); got != want {
+			t.Errorf("Expected reference at %X to be %04X, got %04X", at, want, got)
+		}
+	}
+}
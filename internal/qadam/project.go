@@ -0,0 +1,38 @@
+package qadam
+
+import (
+	"fmt"
+
+	"github.com/chadlyb/qadam/project"
+)
+
+// openProject returns the project at path, if it's a project manifest or a directory holding one, or nil
+func openProject(path string) (*project.Project, error) {
+	manifest, ok := project.Find(path)
+	if !ok {
+		return nil, nil
+	}
+	p, err := project.Load(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if p.Language != "" {
+		fmt.Printf("INFO: Project: %v, translating to %v\n", manifest, p.Language)
+	} else {
+		fmt.Printf("INFO: Project: %v\n", manifest)
+	}
+	return p, nil
+}
+
+// extractedDir returns the extracted directory at path, and the files extracted to it:
+// the extracted directory of the project at path, or path itself with the default files
+func extractedDir(path string) (string, []project.File, error) {
+	p, err := openProject(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if p == nil {
+		return path, project.DefaultFiles, nil
+	}
+	return p.Path(p.Extracted), p.Files, nil
+}
@@ -0,0 +1,66 @@
+package qadam
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/project"
+)
+
+func TestRunProject(t *testing.T) {
+	dir := t.TempDir()
+	writeGame(t, filepath.Join(dir, "game"))
+	// Only some of the files, extracted under other names
+	manifest := `{
+	"game": "game",
+	"language": "en",
+	"extracted": "work",
+	"files": [
+		{"name": "TEXTS.FIL", "codec": "fil", "text": "dialogue.txt", "sizeField": true},
+		{"name": "GAME.EXE", "codec": "exe", "text": "menus.txt"}
+	]
+}`
+	if err := os.WriteFile(filepath.Join(dir, project.ManifestName), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := guessCommand(dir); c == nil || c.name != "extract" {
+		t.Errorf("Expected extract for a project not yet extracted, got %+v", c)
+	}
+	if got := Run("test", []string{"extract", dir}); got != ExitOK {
+		t.Fatalf("Expected extract to succeed, got exit code %v", got)
+	}
+	for _, name := range []string{"dialogue.txt", "menus.txt", "og/GAME.EXE"} {
+		if _, err := os.Stat(filepath.Join(dir, "work", name)); err != nil {
+			t.Errorf("Expected %v to be extracted: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "work", "texts.txt")); err == nil {
+		t.Error("Expected only the project's files to be extracted")
+	}
+
+	dialogue := filepath.Join(dir, "work", "dialogue.txt")
+	text, err := os.ReadFile(dialogue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dialogue, []byte(strings.Replace(string(text), `"Hi"`, `"Ahoj!"`, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := guessCommand(dir); c == nil || c.name != "build" {
+		t.Errorf("Expected build for an extracted project, got %+v", c)
+	}
+	if got := Run("test", []string{"build", filepath.Join(dir, project.ManifestName)}); got != ExitOK {
+		t.Fatalf("Expected build to succeed, got exit code %v", got)
+	}
+	built, err := os.ReadFile(filepath.Join(dir, "built", "TEXTS.FIL"))
+	if err != nil {
+		t.Fatalf("Expected TEXTS.FIL to be built: %v", err)
+	}
+	if len(built) != 18 {
+		t.Errorf("Expected the built TEXTS.FIL to hold the longer string, got % X", built)
+	}
+}
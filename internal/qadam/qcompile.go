@@ -0,0 +1,107 @@
+package qadam
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/chadlyb/qadam/fil"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// processFile parses the text form of a .FIL file and returns its binary contents.
+// Sections marked KEEP are copied from og, the original .FIL file (which may be nil if there are none).
+// If lint is set, the structure must also match og.
+//
+// Problems in the text are added to diags as coming from file name; if there are any, the output is nil.
+func processFile(r io.Reader, og []byte, name string, lint bool, diags *shared.Diagnostics) ([]byte, error) {
+	f, errs, err := fil.ParseTextAll(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range errs {
+		diags.Add(e.Diagnostic(name))
+	}
+	if len(errs) > 0 {
+		return nil, nil
+	}
+
+	if lint && og != nil {
+		mismatches, err := fil.Lint(og, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range mismatches {
+			diags.Add(m.Diagnostic(name))
+		}
+		if len(mismatches) > 0 {
+			return nil, nil
+		}
+	}
+
+	if f.HasKept() {
+		if og == nil {
+			return nil, fmt.Errorf("KEEP sections need the original file")
+		}
+		ogFile, err := fil.Decode(og)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't decode original: %w", err)
+		}
+		if err := f.Splice(ogFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return f.Encode()
+}
+
+// qcompileFromReader compiles the text form read from reader, named name in diagnostics,
+// and writes the result to writer unless the text has errors.
+func qcompileFromReader(reader io.Reader, og []byte, name string, lint bool, writer io.Writer, diags *shared.Diagnostics) error {
+	output, err := processFile(reader, og, name, lint, diags)
+	if err != nil {
+		return fmt.Errorf("qcompile processFile error: %v", err)
+	}
+	if output == nil {
+		return nil
+	}
+
+	_, err = writer.Write(output)
+	if err != nil {
+		return fmt.Errorf("qcompile error writing: %w", err)
+	}
+
+	return nil
+}
+
+// qcompile compiles infile to outfile, leaving outfile untouched if infile has errors.
+func qcompile(infile string, ogfile string, outfile string, lint bool, diags *shared.Diagnostics) error {
+	// Open input file
+	input, err := os.Open(infile)
+	if err != nil {
+		return fmt.Errorf("qcompile error opening '%v': %w", infile, err)
+	}
+	defer input.Close()
+
+	// Read original file for KEEP sections
+	og, err := os.ReadFile(ogfile)
+	if err != nil {
+		return fmt.Errorf("qcompile error reading '%v': %w", ogfile, err)
+	}
+
+	var output bytes.Buffer
+	if err := qcompileFromReader(input, og, filepath.Base(infile), lint, &output, diags); err != nil {
+		return err
+	}
+	if output.Len() == 0 {
+		return nil
+	}
+
+	// Write output file
+	if err := os.WriteFile(outfile, output.Bytes(), 0644); err != nil {
+		return fmt.Errorf("qcompile error writing '%v': %w", outfile, err)
+	}
+	return nil
+}
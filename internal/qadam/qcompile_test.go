@@ -0,0 +1,97 @@
+package qadam
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/fil"
+	"github.com/chadlyb/qadam/shared"
+)
+
+func TestProcessFile(t *testing.T) {
+	// "Hi" in charset: H=0x48, i=0x69; obfuscated with +0x31: 0x79, 0x9A
+	input := "SECTION 0\n[01 02 03 04 05] \"Hi\"\n"
+	expected := []byte{
+		0x01,             // 1 entry
+		0x07, 0x00, 0x00, // offset 1: 7 (start of data section)
+		0x0F, 0x00, 0x00, // offset 2: 15 (end of data)
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A, 0x00,
+	}
+
+	var diags shared.Diagnostics
+	output, err := processFile(strings.NewReader(input), nil, "texts.txt", false, &diags)
+	if err != nil || len(diags) != 0 {
+		t.Fatalf("processFile failed: %v %v", err, diags)
+	}
+	if !bytes.Equal(output, expected) {
+		t.Errorf("Expected %X, got %X", expected, output)
+	}
+}
+
+func TestProcessFileCollectsDiagnostics(t *testing.T) {
+	og := []byte{
+		0x01,             // 1 entry
+		0x07, 0x00, 0x00, // offset 1: 7 (start of data section)
+		0x0F, 0x00, 0x00, // offset 2: 15 (end of data)
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A, 0x00,
+	}
+
+	// Every parse error is reported, and nothing is compiled
+	var diags shared.Diagnostics
+	output, err := processFile(strings.NewReader("SECTION 0\n[01 02 03 04 05] \"€\"\n[01 02 03 04 05] \"€\"\n"), og, "texts.txt", true, &diags)
+	if err != nil {
+		t.Fatalf("processFile failed: %v", err)
+	}
+	if output != nil {
+		t.Error("Expected no output for text with errors")
+	}
+	if len(diags) != 2 || diags[0].Line != 2 || diags[1].Line != 3 || diags[1].Code != fil.CodeMissingCharset {
+		t.Errorf("Expected a missing-charset error on lines 2 and 3, got %v", diags)
+	}
+
+	// Structural mismatches are reported when linting
+	diags = nil
+	output, err = processFile(strings.NewReader("SECTION 0\n[01 02 03 04 06] \"Hi\"\n"), og, "texts.txt", true, &diags)
+	if err != nil {
+		t.Fatalf("processFile failed: %v", err)
+	}
+	if output != nil || len(diags) != 1 || diags[0].Code != fil.CodeHeaderChanged {
+		t.Errorf("Expected a header-changed error, got %v", diags)
+	}
+}
+
+func TestProcessFileWithKeptSections(t *testing.T) {
+	og := []byte{
+		0x02,             // 2 entries
+		0x0A, 0x00, 0x00, // offset 1: 10
+		0x0C, 0x00, 0x00, // offset 2: 12
+		0x13, 0x00, 0x00, // offset 3: 19 (end of data)
+		0xAA, 0xBB, // section 0: opaque data
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x00, // section 1: "H"
+	}
+
+	input := "SECTION 0 KEEP\nSECTION 1\n[01 02 03 04 05] \"Hi\"\n"
+
+	var diags shared.Diagnostics
+	if _, err := processFile(strings.NewReader(input), nil, "resource.txt", false, &diags); err == nil {
+		t.Error("Expected error compiling KEEP sections without the original")
+	}
+
+	output, err := processFile(strings.NewReader(input), og, "resource.txt", true, &diags)
+	if err != nil {
+		t.Fatalf("processFile failed: %v", err)
+	}
+
+	expected := []byte{
+		0x02,
+		0x0A, 0x00, 0x00,
+		0x0C, 0x00, 0x00,
+		0x14, 0x00, 0x00,
+		0xAA, 0xBB,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x79, 0x9A, 0x00,
+	}
+	if !bytes.Equal(output, expected) {
+		t.Errorf("Expected %X, got %X", expected, output)
+	}
+}
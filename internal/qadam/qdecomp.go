@@ -0,0 +1,54 @@
+package qadam
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/chadlyb/qadam/fil"
+)
+
+// qdecompFromReader processes data from an io.Reader and writes results to an io.Writer
+func qdecompFromReader(reader io.Reader, writer io.Writer) error {
+	return qdecompFileFromReader(reader, writer, "")
+}
+
+// qdecompFileFromReader is qdecompFromReader for the named .FIL file: known section layouts are used,
+// and only its editable sections are written out, the rest are left for build to copy from og
+func qdecompFileFromReader(reader io.Reader, writer io.Writer, name string) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read data: %w", err)
+	}
+
+	f, err := fil.DecodeWithLayouts(data, fil.LayoutsFor(name))
+	if err != nil {
+		return err
+	}
+
+	if editable := fil.EditableFor(name); editable != nil {
+		f.KeepExcept(editable...)
+	}
+
+	return f.WriteText(writer)
+}
+
+// qdecomp is the convenience function that maintains the original file path interface
+func qdecomp(inputFile string, outputFile string) error {
+	// Open input file
+	input, err := os.Open(inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to open file '%v': %w", inputFile, err)
+	}
+	defer input.Close()
+
+	// Create output file
+	output, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create file '%v': %w", outputFile, err)
+	}
+	defer output.Close()
+
+	return qdecompFileFromReader(input, output, filepath.Base(inputFile))
+}
@@ -1,4 +1,4 @@
-package main
+package qadam
 
 import (
 	"bytes"
@@ -15,7 +15,7 @@ func TestQDecompFromReader(t *testing.T) {
 		0x07, 0x00, 0x00, // offset 1: 7 (start of data section)
 		0x0C, 0x00, 0x00, // offset 2: 12 (end of data)
 		// Data section (5 bytes)
-		0x48, 0x65, 0x6C, 0x6C, 0x6F, // "Hello" (hex data)
+		0x48, 0x65, 0x6C, 0x6C, 0x6F, // "Hello" (header bytes)
 	}
 
 	// Create input reader and output writer
@@ -52,17 +52,17 @@ func TestQDecompFromReader(t *testing.T) {
 		t.Error("Expected to find 'SECTION 0' in output")
 	}
 
-	// Should contain hex data
-	foundHex := false
+	// Should contain the decoded header of "Hello" (48 65 6C 6C 6F)
+	foundHeader := false
 	for _, line := range lines {
-		if strings.Contains(line, "[48 65 6C 6C 6F]") {
-			foundHex = true
+		if strings.Contains(line, "{id=0x6548 color=12 x=108 y=111 flags=0x06}") {
+			foundHeader = true
 			break
 		}
 	}
 
-	if !foundHex {
-		t.Error("Expected to find hex data '[48 65 6C 6C 6F]' in output")
+	if !foundHeader {
+		t.Error("Expected to find header '{id=0x6548 color=12 x=108 y=111 flags=0x06}' in output")
 	}
 }
 
@@ -114,16 +114,16 @@ func TestQDecompFromReaderWithString(t *testing.T) {
 		t.Error("Expected to find 'SECTION 0' in output")
 	}
 
-	// Should contain the hex run
-	foundHex := false
+	// Should contain the decoded header of 01 02 03 04 05
+	foundHeader := false
 	for _, line := range lines {
-		if strings.Contains(line, "[01 02 03 04 05]") {
-			foundHex = true
+		if strings.Contains(line, "{id=0x0201 color=3 x=4 y=5 flags=0x00}") {
+			foundHeader = true
 			break
 		}
 	}
-	if !foundHex {
-		t.Error("Expected to find '[01 02 03 04 05]' in output")
+	if !foundHeader {
+		t.Error("Expected to find '{id=0x0201 color=3 x=4 y=5 flags=0x00}' in output")
 	}
 
 	// Should contain the string "Hi"
@@ -138,3 +138,33 @@ func TestQDecompFromReaderWithString(t *testing.T) {
 		t.Error("Expected to find '\"Hi\"' in output")
 	}
 }
+
+func TestQDecompFileFromReaderKeepsNonEditableSections(t *testing.T) {
+	// 12 sections; only section 11 of RESOURCE.FIL is editable
+	testData := []byte{0x0C}
+	offset := 1 + 13*3
+	for i := 0; i != 12; i++ {
+		testData = append(testData, byte(offset), 0x00, 0x00)
+		offset += 8
+	}
+	testData = append(testData, byte(offset), 0x00, 0x00)
+	for i := 0; i != 12; i++ {
+		testData = append(testData, 0x01, 0x02, 0x03, 0x04, byte(i), 0x79, 0x9A, 0x00) // "Hi"
+	}
+
+	var writer bytes.Buffer
+	err := qdecompFileFromReader(bytes.NewReader(testData), &writer, "RESOURCE.FIL")
+	if err != nil {
+		t.Fatalf("qdecompFileFromReader failed: %v", err)
+	}
+
+	output := writer.String()
+	t.Logf("Output: %s", output)
+
+	if strings.Count(output, " KEEP\n") != 11 {
+		t.Error("Expected sections 0-10 to be kept")
+	}
+	if strings.Count(output, "\"Hi\"") != 1 || !strings.Contains(output, "SECTION 11\n") {
+		t.Error("Expected only section 11 to be editable")
+	}
+}
@@ -0,0 +1,265 @@
+package qadam
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chadlyb/qadam/edition"
+	"github.com/chadlyb/qadam/mz"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// dataSegment is the data segment of an MZ executable
+type dataSegment struct {
+	exe  *mz.File
+	seg  uint16
+	refs map[uint16][]mz.Reference // what refers to each offset in the segment
+}
+
+// findDataSegment returns the data segment of data if it's an MZ executable with one we can find
+func findDataSegment(data []byte) (*dataSegment, bool) {
+	exe, err := mz.Parse(data)
+	if err != nil {
+		if debugMode {
+			fmt.Printf("DEBUG: Not using MZ header: %v\n", err)
+		}
+		return nil, false
+	}
+	seg, how, ok := exe.DataSegment()
+	if !ok {
+		if debugMode {
+			fmt.Printf("DEBUG: Couldn't find the data segment\n")
+		}
+		return nil, false
+	}
+	if debugMode {
+		fmt.Printf("DEBUG: Data segment %04X (from %v) at %08x-%08x\n", seg, how, exe.FileOffset(seg, 0), exe.ImageEnd)
+	}
+	return &dataSegment{exe, seg, exe.References(seg)}, true
+}
+
+// isReferenced reports whether a confirmed reference refers to file offset at of ds, which may be nil
+func (ds *dataSegment) isReferenced(at int) bool {
+	if ds == nil {
+		return false
+	}
+	off, ok := ds.exe.SegOff(ds.seg, at)
+	if !ok {
+		return false
+	}
+	for _, r := range ds.refs[off] {
+		if r.Confirmed() {
+			return true
+		}
+	}
+	return false
+}
+
+// writeStringLine writes the patch line for the string at data[start:end].
+// Non-text bytes before the string's first letter are written as a hex prefix, so they can't be edited by accident.
+// If ds is set, the string's address in the data segment, and the places that refer to it, are written as a comment.
+func writeStringLine(w io.Writer, data []byte, start, end int, ds *dataSegment) {
+	fmt.Fprintf(w, "%08x-%08x: ", start, end+1)
+	prefix := shared.GarbagePrefixLen(data[start:end])
+	if prefix > 0 {
+		fmt.Fprintf(w, "[% X] ", data[start:start+prefix])
+	}
+	fmt.Fprintf(w, "\"%v\"", shared.ToString(data[start+prefix:end]))
+	if ds != nil {
+		if off, ok := ds.exe.SegOff(ds.seg, start); ok {
+			fmt.Fprintf(w, " ; %04X:%04X, %v", ds.seg, off, describeRefs(ds.refs[off]))
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// writePascalLine writes the patch line for the Pascal string whose length byte is at data[at].
+// The range is the length byte and the text, and the string is marked "pascal" so build keeps the length right.
+func writePascalLine(w io.Writer, data []byte, at int, ds *dataSegment) {
+	end := at + 1 + int(data[at])
+	fmt.Fprintf(w, "%08x-%08x: pascal \"%v\"", at, end, shared.ToString(data[at+1:end]))
+	if ds != nil {
+		// Pascal strings are referred to by their length byte
+		if off, ok := ds.exe.SegOff(ds.seg, at); ok {
+			fmt.Fprintf(w, " ; %04X:%04X, %v", ds.seg, off, describeRefs(ds.refs[off]))
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// writeStrings writes the patch lines for the string at data[start:end], or
+// for the Pascal strings it holds, if it's a run of them. A lone Pascal string
+// is only taken for one if something refers to its length byte.
+func writeStrings(w io.Writer, data []byte, start, end int, ds *dataSegment) {
+	text := start + shared.GarbagePrefixLen(data[start:end])
+	if starts := shared.PascalStrings(data, text, end, ds.isReferenced(text-1)); starts != nil {
+		for _, at := range starts {
+			writePascalLine(w, data, at, ds)
+		}
+		return
+	}
+	writeStringLine(w, data, start, end, ds)
+}
+
+// describeRefs lists the confirmed references of refs for a comment, e.g. "refs: 0000:0010, 0000:0A20",
+// and counts the rest, e.g. "no refs, 2 unconfirmed"
+func describeRefs(refs []mz.Reference) string {
+	var sites []string
+	unconfirmed := 0
+	for _, r := range refs {
+		if r.Confirmed() {
+			sites = append(sites, r.String())
+		} else {
+			unconfirmed++
+		}
+	}
+	s := "no refs"
+	if len(sites) > 0 {
+		s = "refs: " + strings.Join(sites, ", ")
+	}
+	if unconfirmed > 0 {
+		s += fmt.Sprintf(", %v unconfirmed", unconfirmed)
+	}
+	return s
+}
+
+// qgetStringsFromReader processes data from an io.Reader and writes results to an io.Writer.
+// If within is set, it's where text is looked for, rather than the data segment.
+func qgetStringsFromReader(reader io.Reader, writer io.Writer, catchAll bool, within edition.Range) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("couldn't read data: %w", err)
+	}
+
+	if debugMode {
+		fmt.Printf("DEBUG: Read %d bytes\n", len(data))
+	}
+
+	// Crawl for strings, output OFFSET LENGTH "<string>"
+	foundBorland := false
+	totalStrings := 0
+	potentialStrings := 0
+	acceptedStrings := 0
+	skippedBadStart := 0
+
+	pos := 0
+	end := len(data)
+
+	// Text lives in the data segment; without one, accept strings after Borland's
+	// copyright notice, which starts the data segment of Borland programs
+	ds, hasDataSegment := findDataSegment(data)
+	if hasDataSegment && !catchAll {
+		pos = ds.exe.FileOffset(ds.seg, 0)
+		end = ds.exe.ImageEnd
+	}
+	if within != (edition.Range{}) && !catchAll {
+		if within.Begin < 0 || within.Begin > within.End || within.End > len(data) {
+			return fmt.Errorf("string range %08x-%08x is outside the file (%v bytes)", within.Begin, within.End, len(data))
+		}
+		pos, end = within.Begin, within.End
+		hasDataSegment = true
+	}
+
+	for pos < end {
+		// Find the next valid string starting from current position
+		stringStart, stringEnd, found := shared.FindNextValidString(data, pos, end, catchAll)
+
+		if !found {
+			// No valid string found, move to next position and continue
+			pos = stringEnd + 1
+			continue
+		}
+
+		// Calculate the original start position for this string
+		pos = stringEnd + 1
+
+		// A string running to the end of the file has no NUL, so it can't be patched
+		if stringEnd >= end {
+			continue
+		}
+
+		potentialStrings++
+
+		// Generate the string content from the range
+		stringContent := shared.ToString(data[stringStart:stringEnd])
+
+		// Debug: Check for Borland string
+		if !foundBorland && strings.Contains(stringContent, "Borland") {
+			foundBorland = true
+			if debugMode {
+				fmt.Printf("DEBUG: Found Borland string at offset %08x: \"%s\"\n", stringStart, stringContent)
+			}
+		}
+
+		// Debug: Show all potential strings when in debug mode
+		if debugMode {
+			fmt.Printf("DEBUG: Potential string %d at %08x: \"%s\" (len=%d)\n",
+				potentialStrings, stringStart, stringContent, len(stringContent))
+		}
+
+		// In catch-all mode, process all strings regardless of Borland or length
+		if catchAll || hasDataSegment || foundBorland {
+			totalStrings++
+			if catchAll {
+				acceptedStrings++
+				writeStrings(writer, data, stringStart, stringEnd, ds)
+				if debugMode {
+					fmt.Printf("DEBUG: ACCEPTED string %d: \"%s\"\n", acceptedStrings, stringContent)
+				}
+				continue
+			}
+			// Conservative mode: Convert string back to bytes for language detection
+			stringBytes, err := shared.FromString(stringContent)
+			if err != nil {
+				if debugMode {
+					fmt.Printf("DEBUG: Error converting string back to bytes: %v\n", err)
+				}
+				continue
+			}
+			isLikely := shared.IsLikelyHumanLanguage(stringBytes)
+			if isLikely {
+				acceptedStrings++
+				writeStrings(writer, data, stringStart, stringEnd, ds)
+				if debugMode {
+					fmt.Printf("DEBUG: ACCEPTED string %d: \"%s\"\n", acceptedStrings, stringContent)
+				}
+			} else {
+				if debugMode {
+					fmt.Printf("DEBUG: REJECTED string %d: \"%s\"\n", totalStrings, stringContent)
+				}
+			}
+		}
+	}
+
+	if debugMode {
+		fmt.Printf("DEBUG: Summary:\n")
+		fmt.Printf("  - Total potential strings: %d\n", potentialStrings)
+		fmt.Printf("  - Strings after Borland: %d\n", totalStrings)
+		fmt.Printf("  - Accepted as Czech: %d\n", acceptedStrings)
+		fmt.Printf("  - Skipped bad start: %d\n", skippedBadStart)
+		fmt.Printf("  - Found data segment: %v\n", hasDataSegment)
+		fmt.Printf("  - Found Borland marker: %v\n", foundBorland)
+		fmt.Printf("  - Catch-all mode: %v\n", catchAll)
+	}
+
+	return nil
+}
+
+// qgetStrings extracts strings from a file and writes them to another file
+func qgetStrings(srcPath, destPath string, allStrings bool, within edition.Range) error {
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("couldn't open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	destFile, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("couldn't create destination file: %w", err)
+	}
+	defer destFile.Close()
+
+	return qgetStringsFromReader(srcFile, destFile, allStrings, within)
+}
@@ -1,4 +1,4 @@
-package main
+package qadam
 
 import (
 	"bytes"
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/chadlyb/qadam/edition"
+	"github.com/chadlyb/qadam/mz"
 	"github.com/chadlyb/qadam/shared"
 )
 
@@ -40,7 +42,7 @@ func TestQGetStrings(t *testing.T) {
 	}
 
 	t.Run("Conservative", func(t *testing.T) {
-		err = qgetStrings(testFile, outputFile, false)
+		err = qgetStrings(testFile, outputFile, false, edition.Range{})
 		if err != nil {
 			t.Fatalf("qgetStrings failed: %v", err)
 		}
@@ -75,7 +77,7 @@ func TestQGetStrings(t *testing.T) {
 	})
 
 	t.Run("CatchAll", func(t *testing.T) {
-		err = qgetStrings(testFile, outputFile, true)
+		err = qgetStrings(testFile, outputFile, true, edition.Range{})
 		if err != nil {
 			t.Fatalf("qgetStrings (catchAll) failed: %v", err)
 		}
@@ -127,7 +129,7 @@ func TestQGetStringsFromReader(t *testing.T) {
 	t.Run("Conservative", func(t *testing.T) {
 		reader := bytes.NewReader(testData)
 		var writer bytes.Buffer
-		err := qgetStringsFromReader(reader, &writer, false)
+		err := qgetStringsFromReader(reader, &writer, false, edition.Range{})
 		if err != nil {
 			t.Fatalf("qgetStringsFromReader failed: %v", err)
 		}
@@ -174,7 +176,7 @@ func TestQGetStringsFromReader(t *testing.T) {
 	t.Run("CatchAll", func(t *testing.T) {
 		reader := bytes.NewReader(testData)
 		var writer bytes.Buffer
-		err := qgetStringsFromReader(reader, &writer, true)
+		err := qgetStringsFromReader(reader, &writer, true, edition.Range{})
 		if err != nil {
 			t.Fatalf("qgetStringsFromReader (catchAll) failed: %v", err)
 		}
@@ -267,3 +269,97 @@ func containsSubstring(haystack, needle string) bool {
 	}
 	return false
 }
+
+func TestQGetStringsFromReaderPrefix(t *testing.T) {
+	// "\x01\x02New Game\0": two bytes of data before the text
+	testData := []byte{0x01, 0x02, 0x4E, 0x65, 0x77, 0x20, 0x47, 0x61, 0x6D, 0x65, 0x00}
+
+	var writer bytes.Buffer
+	if err := qgetStringsFromReader(bytes.NewReader(testData), &writer, true, edition.Range{}); err != nil {
+		t.Fatalf("qgetStringsFromReader failed: %v", err)
+	}
+
+	expected := "00000000-0000000b: [01 02] \"New Game\"\n"
+	if writer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, writer.String())
+	}
+}
+
+func TestQGetStringsFromReaderDataSegment(t *testing.T) {
+	// Load image: segment 0 holds the startup code, which refers to "Quit game",
+	// and some text that isn't in the data segment; segment 1 is the data segment
+	image := []byte{0xBA, 0x01, 0x00, 0xB8, 0x0D, 0x00} // mov dx, 0001; mov ax, 000D
+	image = append(image, "Code text\x00"...)
+	image = append(image, make([]byte, 16-len(image))...)
+	image = append(image, "\x00\x00Start game\x00Quit game\x00"...)
+
+	size := 32 + len(image)
+	header := mz.Header{
+		LastPageBytes:    uint16(size % 512),
+		Pages:            uint16((size + 511) / 512),
+		Relocations:      1,
+		HeaderParagraphs: 2,
+		RelocOffset:      mz.HeaderSize,
+	}
+	data := header.Encode()
+	data = append(data, 0x01, 0x00, 0x00, 0x00) // relocation at 0000:0001, the immediate of mov dx
+	data = append(data, make([]byte, 32-len(data))...)
+	data = append(data, image...)
+
+	var writer bytes.Buffer
+	if err := qgetStringsFromReader(bytes.NewReader(data), &writer, false, edition.Range{}); err != nil {
+		t.Fatalf("qgetStringsFromReader failed: %v", err)
+	}
+
+	expected := "00000032-0000003d: \"Start game\" ; 0001:0002, no refs\n" +
+		"0000003d-00000047: \"Quit game\" ; 0001:000D, refs: 0000:0004\n"
+	if writer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, writer.String())
+	}
+}
+
+func TestQGetStringsFromReaderWithin(t *testing.T) {
+	data := []byte("Start game\x00Quit game\x00Options\x00")
+
+	var writer bytes.Buffer
+	if err := qgetStringsFromReader(bytes.NewReader(data), &writer, false, edition.Range{Begin: 11, End: 21}); err != nil {
+		t.Fatalf("qgetStringsFromReader failed: %v", err)
+	}
+	expected := "0000000b-00000015: \"Quit game\"\n"
+	if writer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, writer.String())
+	}
+
+	if err := qgetStringsFromReader(bytes.NewReader(data), &writer, false, edition.Range{Begin: 11, End: 100}); err == nil {
+		t.Error("Expected an error for a range past the end of the file")
+	}
+}
+
+func TestQGetStringsFromReaderPascal(t *testing.T) {
+	data := []byte("\x00\x08New Game\x04Quit\x00\x00Options\x00")
+
+	var writer bytes.Buffer
+	if err := qgetStringsFromReader(bytes.NewReader(data), &writer, true, edition.Range{}); err != nil {
+		t.Fatalf("qgetStringsFromReader failed: %v", err)
+	}
+	expected := "00000001-0000000a: pascal \"New Game\"\n" +
+		"0000000a-0000000f: pascal \"Quit\"\n" +
+		"00000011-00000019: \"Options\"\n"
+	if writer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, writer.String())
+	}
+}
+
+func TestQGetStringsFromReaderNotPascal(t *testing.T) {
+	// The byte before "New Game" happens to be its length, but nothing else says it's a Pascal string
+	data := []byte("\x00\x08New Game\x00")
+
+	var writer bytes.Buffer
+	if err := qgetStringsFromReader(bytes.NewReader(data), &writer, true, edition.Range{}); err != nil {
+		t.Fatalf("qgetStringsFromReader failed: %v", err)
+	}
+	expected := "00000001-0000000b: [08] \"New Game\"\n"
+	if writer.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, writer.String())
+	}
+}
@@ -0,0 +1,455 @@
+package qadam
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chadlyb/qadam/shared"
+)
+
+// Expects lines in the format:
+// BEGIN-END: [PREFIX] "STRING" ; COMMENT
+// BEGIN-END: pascal "STRING" ; COMMENT
+//
+// BEGIN-END: is followed by a hex offset, and a colon.
+// [PREFIX] is optional: hex bytes before the string that aren't text, and must not change.
+// pascal marks a Pascal string: the range is its length byte and text, with no NUL.
+// STRING is a quoted string.
+// ; COMMENT is optional (and ignored)
+const lineRegexSrc = `^\s*(?:0x)?(?P<begin>[0-9a-fA-F]+)\s*-\s*(?:0x)?(?P<end>[0-9a-fA-F]+)\s*:\s*(?:\[(?P<prefix>[0-9a-fA-F\s]*)\]\s*)?(?P<pascal>pascal\s+)?"(?P<string>(?:[^"\\]|\\"|\\n|\\\\|\\t|\\r)*)"\s*(?:;.*)?$`
+
+var lineRegex = regexp.MustCompile(lineRegexSrc)
+
+var (
+	beginGroup  = lineRegex.SubexpIndex("begin")
+	endGroup    = lineRegex.SubexpIndex("end")
+	prefixGroup = lineRegex.SubexpIndex("prefix")
+	pascalGroup = lineRegex.SubexpIndex("pascal")
+	stringGroup = lineRegex.SubexpIndex("string")
+)
+
+// Codes of the problems found in patch files
+const (
+	codeBadFormat     = "bad-format"
+	codeBadRange      = "bad-range"
+	codeBadString     = "bad-string"
+	codeTooLong       = "too-long"
+	codePrefixChanged = "prefix-changed"
+	codeNoTerminator  = "no-terminator"
+	codeBadLength     = "bad-length"
+	codeFormatChanged = "format-changed"
+	codeOutOfOrder    = "out-of-order"
+	codeOverlap       = "overlap"
+	codeRelocated     = "relocated"
+	codePacked        = "packed"
+)
+
+// lineError is a problem with one line of a patch file
+type lineError struct {
+	code   string
+	column int // in characters, from 1
+	err    error
+	fix    string
+}
+
+func (e *lineError) Error() string {
+	return e.err.Error()
+}
+
+// diagnostic reports the error on the given line; it is a warning unless strict is set
+func (e *lineError) diagnostic(file string, line int, strict bool) shared.Diagnostic {
+	d := shared.Diagnostic{
+		File:     file,
+		Line:     line,
+		Column:   e.column,
+		Severity: shared.SeverityError,
+		Code:     e.code,
+		Message:  e.err.Error(),
+		Fix:      e.fix,
+	}
+	if !strict {
+		d.Severity = shared.SeverityWarning
+		d.Message += "; line ignored"
+	}
+	return d
+}
+
+// columnOf returns the column of byte index i in line
+func columnOf(line string, i int) int {
+	return utf8.RuneCountInString(line[:i]) + 1
+}
+
+// patchOptions controls how a patch is applied
+type patchOptions struct {
+	strict   bool // lines that can't be applied are errors rather than warnings
+	relocate bool // move strings that are too long to free space
+	pack     bool // make room for strings that are too long by packing the strings around them
+
+	trustRefs bool // move strings even if what may refer to them can't all be confirmed as references
+}
+
+// patchLine is a parsed line of a patch file
+type patchLine struct {
+	num        int
+	begin, end uint64
+	bytes      []byte // the prefix and string to write, without the NUL or length byte
+	pascal     bool   // a Pascal string, written after its length byte rather than before a NUL
+	tooLong    bool   // the string doesn't fit, and is to be packed or relocated
+	packed     bool   // the string was written by packing
+
+	// Columns for diagnostics
+	beginCol, prefixCol, stringCol int
+}
+
+// isBlankOrComment reports whether line of a patch file holds nothing but space or a ; comment
+func isBlankOrComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, ";")
+}
+
+// parseLine parses line number num of a patch file
+func parseLine(num int, line string) (*patchLine, *lineError) {
+	matches := lineRegex.FindStringSubmatchIndex(line)
+	if matches == nil {
+		return nil, &lineError{codeBadFormat, 1, errors.New("line didn't match expected format"),
+			`write lines as BEGIN-END: "string" ; comment, e.g. 00001236-0000123f: "New Game"`}
+	}
+	group := func(i int) string {
+		return line[matches[2*i]:matches[2*i+1]]
+	}
+
+	p := &patchLine{num: num}
+	p.beginCol = columnOf(line, matches[2*beginGroup])
+	p.stringCol = columnOf(line, matches[2*stringGroup]-1) // the opening quote
+	p.prefixCol = p.stringCol
+	if matches[2*prefixGroup] >= 0 {
+		p.prefixCol = columnOf(line, matches[2*prefixGroup]-1) // the opening bracket
+	}
+
+	var err error
+	p.begin, err = strconv.ParseUint(group(beginGroup), 16, 64)
+	if err != nil {
+		return nil, &lineError{codeBadRange, p.beginCol, fmt.Errorf("couldn't parse begin offset: %w", err), ""}
+	}
+	p.end, err = strconv.ParseUint(group(endGroup), 16, 64)
+	if err != nil {
+		return nil, &lineError{codeBadRange, columnOf(line, matches[2*endGroup]), fmt.Errorf("couldn't parse end offset: %w", err), ""}
+	}
+
+	p.pascal = matches[2*pascalGroup] >= 0
+	if p.pascal && matches[2*prefixGroup] >= 0 {
+		return nil, &lineError{codeBadFormat, p.prefixCol, errors.New("a Pascal string can't have a prefix"),
+			"restore the line from a fresh extract"}
+	}
+
+	if matches[2*prefixGroup] >= 0 {
+		p.bytes, err = hex.DecodeString(strings.Join(strings.Fields(group(prefixGroup)), ""))
+		if err != nil {
+			return nil, &lineError{codeBadFormat, p.prefixCol, fmt.Errorf("invalid hex prefix: %w", err),
+				"restore the prefix from a fresh extract"}
+		}
+	}
+	stringBytes, err := shared.FromString(group(stringGroup))
+	if err != nil {
+		return nil, &lineError{codeBadString, p.stringCol, fmt.Errorf("couldn't translate string: %w", err),
+			"replace characters the game's code page 852 font doesn't have"}
+	}
+	p.bytes = append(p.bytes, stringBytes...)
+	return p, nil
+}
+
+// check reports whether the line can be applied to data, the original file
+func (p *patchLine) check(data []byte) *lineError {
+	if p.begin >= p.end || p.end > uint64(len(data)) {
+		return &lineError{codeBadRange, p.beginCol,
+			fmt.Errorf("range %X-%X isn't within the file (%X bytes)", p.begin, p.end, len(data)),
+			"restore the range from a fresh extract"}
+	}
+	if p.pascal {
+		return p.checkPascal(data)
+	}
+	if data[p.end-1] != 0 {
+		return &lineError{codeNoTerminator, p.beginCol,
+			fmt.Errorf("range %X-%X doesn't end with the original string's NUL", p.begin, p.end),
+			"restore the range from a fresh extract"}
+	}
+
+	// Bytes before the original string's text are probably data, and must survive
+	og := data[p.begin : p.end-1]
+	ogPrefix := og[:shared.GarbagePrefixLen(og)]
+	if !bytes.HasPrefix(p.bytes, ogPrefix) {
+		return &lineError{codePrefixChanged, p.prefixCol,
+			fmt.Errorf("the original starts with non-text bytes [% X], which the patch changes", ogPrefix),
+			fmt.Sprintf("put [% X] back before the string", ogPrefix)}
+	}
+	if err := p.checkFormat(og[len(ogPrefix):], p.bytes[len(ogPrefix):]); err != nil {
+		return err
+	}
+
+	size := uint64(len(p.bytes)) + 1
+	if size > p.end-p.begin {
+		return &lineError{codeTooLong, p.stringCol, fmt.Errorf("string too long (%v > %v bytes)", size, p.end-p.begin),
+			fmt.Sprintf("shorten the string to at most %v bytes", p.end-p.begin-1-uint64(len(ogPrefix)))}
+	}
+	return nil
+}
+
+// checkPascal is check for a Pascal string
+func (p *patchLine) checkPascal(data []byte) *lineError {
+	if n := uint64(data[p.begin]); n != p.end-p.begin-1 {
+		return &lineError{codeBadLength, p.beginCol,
+			fmt.Errorf("range %X-%X doesn't hold a Pascal string: its length byte is %v, not %v", p.begin, p.end, n, p.end-p.begin-1),
+			"restore the range from a fresh extract"}
+	}
+	if err := p.checkFormat(data[p.begin+1:p.end], p.bytes); err != nil {
+		return err
+	}
+	if size := uint64(len(p.bytes)) + 1; size > p.end-p.begin {
+		return &lineError{codeTooLong, p.stringCol, fmt.Errorf("string too long (%v > %v bytes)", size, p.end-p.begin),
+			fmt.Sprintf("shorten the string to at most %v bytes", p.end-p.begin-1)}
+	}
+	return nil
+}
+
+func (p *patchLine) apply(data []byte) {
+	if p.pascal {
+		// What's left of the range is cleared, so no old text is taken for part of the string
+		data[p.begin] = byte(len(p.bytes))
+		copy(data[p.begin+1:], p.bytes)
+		clear(data[p.begin+1+uint64(len(p.bytes)) : p.end])
+		return
+	}
+	copy(data[p.begin:], p.bytes)
+	data[p.begin+uint64(len(p.bytes))] = 0
+}
+
+// checkOrder rejects lines that aren't in increasing order of offset, or that
+// overlap another line, and returns the rest
+func checkOrder(lines []*patchLine, fail func(*patchLine, *lineError)) []*patchLine {
+	// Each line is compared with the last one kept, so what's kept is sorted
+	var sorted []*patchLine
+	for _, p := range lines {
+		if len(sorted) > 0 {
+			if prev := sorted[len(sorted)-1]; p.begin < prev.begin {
+				fail(p, &lineError{codeOutOfOrder, p.beginCol,
+					fmt.Errorf("range %X-%X comes before line %v's (%X-%X)", p.begin, p.end, prev.num, prev.begin, prev.end),
+					"keep lines in order of offset, as extracted"})
+				continue
+			}
+		}
+		sorted = append(sorted, p)
+	}
+
+	// The line after each overlap is rejected
+	var ok []*patchLine
+	for _, p := range sorted {
+		if len(ok) > 0 {
+			prev := ok[len(ok)-1]
+			if p.begin < prev.end {
+				fail(p, &lineError{codeOverlap, p.beginCol,
+					fmt.Errorf("range %X-%X overlaps line %v's (%X-%X)", p.begin, p.end, prev.num, prev.begin, prev.end),
+					"restore the ranges from a fresh extract"})
+				continue
+			}
+		}
+		ok = append(ok, p)
+	}
+	return ok
+}
+
+// qpatchStringsFromReader processes data from io.Reader and patch data from io.Reader, writing results to io.Writer.
+// The patch is checked as a whole: ranges must be in order, must not overlap, must be within the file,
+// and must end with the original string's NUL. Lines that can't be applied are skipped and added to
+// diags as coming from file name: as errors if opts.strict is set, otherwise as warnings.
+//
+// With opts.pack, strings too long for their place take space from the strings after them:
+// strings back to back are laid out anew, and the code that refers to them is changed to match.
+// With opts.relocate, strings that still don't fit are moved to space freed by other strings
+// of the patch. Each string moved is noted in diags.
+func qpatchStringsFromReader(srcReader io.Reader, destWriter io.Writer, patchReader io.Reader, name string, opts patchOptions, diags *shared.Diagnostics) error {
+	// Read source data
+	data, err := io.ReadAll(srcReader)
+	if err != nil {
+		return fmt.Errorf("couldn't read source data: %w", err)
+	}
+
+	// Parse patch data, line by line; nothing is applied until the whole file has been checked
+	var found shared.Diagnostics
+	fail := func(num int, err *lineError) {
+		found.Add(err.diagnostic(name, num, opts.strict))
+	}
+
+	var lines []*patchLine
+	scanner := bufio.NewScanner(patchReader)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if isBlankOrComment(scanner.Text()) {
+			continue
+		}
+		p, err := parseLine(lineNum, scanner.Text())
+		if err == nil {
+			err = p.check(data)
+		}
+		// Only NUL-terminated strings are moved; Pascal strings must fit where they are
+		if err != nil && err.code == codeTooLong && (opts.pack || opts.relocate) && !p.pascal {
+			p.tooLong = true
+			err = nil
+		}
+		if err != nil {
+			fail(lineNum, err)
+			continue
+		}
+		lines = append(lines, p)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("couldn't scan patch data: %w", err)
+	}
+
+	lines = checkOrder(lines, func(p *patchLine, err *lineError) { fail(p.num, err) })
+	for _, p := range lines {
+		if !p.tooLong {
+			p.apply(data)
+		}
+	}
+
+	if opts.pack || opts.relocate {
+		moveStrings(data, lines, name, opts, fail, &found)
+	}
+
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Line < found[j].Line })
+	for _, d := range found {
+		diags.Add(d)
+	}
+
+	// Write to output
+	_, err = destWriter.Write(data)
+	if err != nil {
+		return fmt.Errorf("couldn't write output: %w", err)
+	}
+
+	return nil
+}
+
+// moveStrings makes room for the strings of lines that are too long, once the others have been applied
+func moveStrings(data []byte, lines []*patchLine, name string, opts patchOptions, fail func(int, *lineError), found *shared.Diagnostics) {
+	var tooLong []*patchLine
+	for _, p := range lines {
+		if p.tooLong {
+			tooLong = append(tooLong, p)
+		}
+	}
+	if len(tooLong) == 0 {
+		return
+	}
+
+	r, err := newRelocator(data, lines)
+	if err != nil {
+		for _, p := range tooLong {
+			fail(p.num, &lineError{codeTooLong, p.stringCol,
+				fmt.Errorf("string too long (%v > %v bytes), and it can't be moved: %w", len(p.bytes)+1, p.end-p.begin, err),
+				fmt.Sprintf("shorten the string to at most %v bytes", p.end-p.begin-1)})
+		}
+		return
+	}
+	r.trust = opts.trustRefs
+
+	note := func(p *patchLine, code, message string) {
+		found.Add(shared.Diagnostic{
+			File:     name,
+			Line:     p.num,
+			Column:   p.stringCol,
+			Severity: shared.SeverityNote,
+			Code:     code,
+			Message:  message,
+		})
+	}
+
+	if opts.pack {
+		for _, m := range r.pack(lines) {
+			sites := make([]string, len(m.refs))
+			for i, ref := range m.refs {
+				sites[i] = ref.String()
+			}
+			note(m.line, codePacked, fmt.Sprintf("string moved from %04X:%04X to %04X:%04X; references changed at %v%v",
+				r.ds, m.from, r.ds, m.to, strings.Join(sites, ", "), unchanged(r, m.from)))
+		}
+	}
+
+	// Shortened strings leave their tails free
+	for _, p := range lines {
+		if !p.tooLong && !p.packed && !p.pascal {
+			r.reclaim(span{int(p.begin) + len(p.bytes) + 1, int(p.end)})
+		}
+	}
+
+	for _, p := range tooLong {
+		if !p.tooLong {
+			// Packed
+			continue
+		}
+		if !opts.relocate {
+			_, reason := r.movable(p)
+			if reason == "" {
+				reason = "the strings after it have no room to spare; shorten them, or build with -relocate"
+			}
+			fail(p.num, tooLongError(p, reason))
+			continue
+		}
+		from, _ := r.exe.SegOff(r.ds, int(p.begin))
+		off, refs, lerr := r.relocate(p)
+		if lerr != nil {
+			fail(p.num, lerr)
+			continue
+		}
+		note(p, codeRelocated, fmt.Sprintf("string moved to %04X:%04X, %v reference(s) changed%v", r.ds, off, len(refs), unchanged(r, from)))
+	}
+}
+
+// unchanged describes what may still refer to offset from, where a string
+// was moved from with -trust-refs, for the note about the move
+func unchanged(r *relocator, from uint16) string {
+	doubtful := r.doubtful(from)
+	if len(doubtful) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("; %v may also refer to its old place, and wasn't changed", describeDoubtful(doubtful))
+}
+
+// qpatchStrings is the convenience function that maintains the original file path interface
+func qpatchStrings(srcPath string, destPath string, patchPath string, opts patchOptions, diags *shared.Diagnostics) error {
+	// Open source file
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("couldn't read source file %s: %w", srcPath, err)
+	}
+	defer srcFile.Close()
+
+	// Open patch file
+	patchFile, err := os.Open(patchPath)
+	if err != nil {
+		return fmt.Errorf("couldn't open patch file %s: %w", patchPath, err)
+	}
+	defer patchFile.Close()
+
+	// Create destination file
+	destFile, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("couldn't create destination file %s: %w", destPath, err)
+	}
+	defer destFile.Close()
+
+	return qpatchStringsFromReader(srcFile, destFile, patchFile, filepath.Base(patchPath), opts, diags)
+}
@@ -0,0 +1,336 @@
+package qadam
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/shared"
+)
+
+func TestQPatchStringsFromReader(t *testing.T) {
+	// Test source data (simple executable-like data)
+	srcData := []byte{
+		// Some header bytes
+		0x4D, 0x5A, 0x90, 0x00, 0x03, 0x00, 0x00, 0x00,
+		// Some data that will be patched
+		0x48, 0x65, 0x6C, 0x6C, 0x00, // "Hell\0"
+		0x20, 0x57, 0x6F, 0x72, 0x6C, 0x64, 0x21, 0x21, 0x00, // " World!!\0"
+		// More data
+		0x54, 0x68, 0x69, 0x73, 0x20, 0x69, 0x73, 0x20, 0x74, 0x65, 0x73, 0x74, 0x00, // "This is test\0"
+	}
+
+	// Test patch data - fix the ranges to match the string lengths
+	patchData := "00000008-0000000D:\"Ahoj\"\n0000000E-00000016:\"Svět\"\n"
+
+	// Create readers and writer
+	srcReader := bytes.NewReader(srcData)
+	patchReader := strings.NewReader(patchData)
+	var destWriter bytes.Buffer
+
+	// Run the function
+	var diags shared.Diagnostics
+	err := qpatchStringsFromReader(srcReader, &destWriter, patchReader, "game_exe.txt", patchOptions{}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+
+	output := destWriter.Bytes()
+	t.Logf("Output length: %d bytes", len(output))
+
+	// Verify the patches were applied correctly
+	// Original: "Hell\0" at offset 8-13
+	// Patched: "Ahoj\0" at offset 8-13
+	expectedPatch1 := []byte{0x41, 0x68, 0x6F, 0x6A, 0x00} // "Ahoj\0" in charset
+	if !bytes.Equal(output[8:13], expectedPatch1) {
+		t.Errorf("First patch failed. Expected %v, got %v", expectedPatch1, output[8:13])
+	}
+
+	// Original: "World!!\0" at offset 14-22
+	// Patched: "Svět\0" at offset 14-19
+	expectedPatch2 := []byte{0x53, 0x76, 0xD8, 0x74, 0x00} // "Svět\0" in charset
+	if !bytes.Equal(output[14:19], expectedPatch2) {
+		t.Errorf("Second patch failed. Expected %v, got %v", expectedPatch2, output[14:19])
+	}
+
+	// Verify other data remains unchanged
+	if output[0] != 0x4D || output[1] != 0x5A {
+		t.Error("Header data was modified unexpectedly")
+	}
+}
+
+func TestQPatchStringsFromReaderWithInvalidPatch(t *testing.T) {
+	// Test source data
+	srcData := []byte{
+		0x48, 0x65, 0x6C, 0x6C, 0x6F, 0x00, // "Hello\0"
+	}
+
+	// Test patch data with invalid format (should be ignored with warning)
+	patchData := "invalid format line\n00000000-00000006:\"Ahoj\"\n"
+
+	// Create readers and writer
+	srcReader := bytes.NewReader(srcData)
+	patchReader := strings.NewReader(patchData)
+	var destWriter bytes.Buffer
+
+	// Run the function - should not fail, just warn about invalid line
+	var diags shared.Diagnostics
+	err := qpatchStringsFromReader(srcReader, &destWriter, patchReader, "game_exe.txt", patchOptions{}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+	if len(diags) != 1 || diags[0].Line != 1 || diags[0].Severity != shared.SeverityWarning || diags[0].Code != codeBadFormat {
+		t.Errorf("Expected a bad-format warning for line 1, got %v", diags)
+	}
+
+	output := destWriter.Bytes()
+	t.Logf("Output length: %d bytes", len(output))
+
+	// Verify the valid patch was applied
+	expectedPatch := []byte{0x41, 0x68, 0x6F, 0x6A, 0x00} // "Ahoj\0" in charset
+	if !bytes.Equal(output[0:5], expectedPatch) {
+		t.Errorf("Valid patch failed. Expected %v, got %v", expectedPatch, output[0:5])
+	}
+}
+
+func TestQPatchStringsFromReaderCollectsEveryProblem(t *testing.T) {
+	srcData := []byte("Hello\x00World\x00")
+
+	patchData := "00000000-00000006: \"Ahoj světe\" ; too long\n" +
+		"00000006-00000010: \"Svět\" ; past the end\n" +
+		"00000006-0000000C:   \"€\"\n" +
+		"00000006-0000000C: \"Svět\"\n"
+
+	var diags shared.Diagnostics
+	var destWriter bytes.Buffer
+	err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", patchOptions{}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+
+	expected := []shared.Diagnostic{
+		{File: "game_exe.txt", Line: 1, Column: 20, Code: codeTooLong},
+		{File: "game_exe.txt", Line: 2, Column: 1, Code: codeBadRange},
+		{File: "game_exe.txt", Line: 3, Column: 22, Code: codeBadString},
+	}
+	if len(diags) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %v", len(expected), diags)
+	}
+	for i, e := range expected {
+		d := diags[i]
+		if d.File != e.File || d.Line != e.Line || d.Column != e.Column || d.Code != e.Code {
+			t.Errorf("Diagnostic %d: expected %v at %v:%v, got %v", i, e.Code, e.Line, e.Column, d)
+		}
+	}
+
+	if !bytes.Equal(destWriter.Bytes()[6:11], []byte{0x53, 0x76, 0xD8, 0x74, 0x00}) {
+		t.Errorf("Expected the valid line to be applied, got %X", destWriter.Bytes())
+	}
+}
+
+func TestQPatchStringsFromReaderStrict(t *testing.T) {
+	srcData := []byte("Hello\x00")
+	patchData := "00000000-00000006: \"Ahoj světe\"\n"
+
+	for _, strict := range []bool{true, false} {
+		var diags shared.Diagnostics
+		var destWriter bytes.Buffer
+		err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", patchOptions{strict: strict}, &diags)
+		if err != nil {
+			t.Fatalf("qpatchStringsFromReader failed: %v", err)
+		}
+		if len(diags) != 1 || diags.HasErrors() != strict {
+			t.Errorf("strict=%v: expected one diagnostic that is an error only in strict mode, got %v", strict, diags)
+		}
+		if !bytes.Equal(destWriter.Bytes(), srcData) {
+			t.Errorf("strict=%v: expected the rejected line to leave the data unchanged, got %q", strict, destWriter.Bytes())
+		}
+	}
+}
+
+func TestQPatchStringsFromReaderPrefix(t *testing.T) {
+	// "\x01\x02New Game\0": two bytes of data before the text
+	srcData := []byte{0x01, 0x02, 0x4E, 0x65, 0x77, 0x20, 0x47, 0x61, 0x6D, 0x65, 0x00}
+
+	testCases := []struct {
+		name     string
+		patch    string
+		expected []byte // nil if the line is rejected
+	}{
+		{"Hex prefix kept", "00000000-0000000b: [01 02] \"Hra\"", []byte{0x01, 0x02, 'H', 'r', 'a', 0x00}},
+		{"Prefix in string kept", "00000000-0000000b: \"☺☻Hra\"", []byte{0x01, 0x02, 'H', 'r', 'a', 0x00}},
+		{"Hex prefix changed", "00000000-0000000b: [01 03] \"Hra\"", nil},
+		{"Hex prefix dropped", "00000000-0000000b: \"Hra\"", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var diags shared.Diagnostics
+			var destWriter bytes.Buffer
+			err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(tc.patch), "game_exe.txt", patchOptions{strict: true}, &diags)
+			if err != nil {
+				t.Fatalf("qpatchStringsFromReader failed: %v", err)
+			}
+
+			if tc.expected == nil {
+				if len(diags) != 1 || diags[0].Code != codePrefixChanged || diags[0].Column != 20 {
+					t.Errorf("Expected a prefix-changed error at column 20, got %v", diags)
+				}
+				if !bytes.Equal(destWriter.Bytes(), srcData) {
+					t.Errorf("Expected data to be unchanged, got %X", destWriter.Bytes())
+				}
+				return
+			}
+
+			if len(diags) != 0 {
+				t.Fatalf("Unexpected diagnostics %v", diags)
+			}
+			if !bytes.Equal(destWriter.Bytes()[:len(tc.expected)], tc.expected) {
+				t.Errorf("Expected %X, got %X", tc.expected, destWriter.Bytes())
+			}
+		})
+	}
+}
+
+func TestQPatchStringsFromReaderChecksRanges(t *testing.T) {
+	srcData := []byte("One\x00Two\x00Three\x00Four\x00")
+
+	patchData := "00000004-00000008: \"Dva\"\n" + // 1: fine
+		"00000000-00000004: \"Jed\"\n" + // 2: before line 1
+		"00000008-0000000E: \"Tři\"\n" + // 3: fine
+		"0000000A-0000000E: \"ři\"\n" + // 4: overlaps line 3
+		"0000000E-00000012: \"Čty\"\n" + // 5: doesn't end on a NUL
+		"0000000E-00000040: \"Čtyři\"\n" // 6: past the end
+
+	var diags shared.Diagnostics
+	var destWriter bytes.Buffer
+	err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "install_exe.txt", patchOptions{strict: true}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+
+	expected := []struct {
+		line int
+		code string
+	}{
+		{2, codeOutOfOrder},
+		{4, codeOverlap},
+		{5, codeNoTerminator},
+		{6, codeBadRange},
+	}
+	if len(diags) != len(expected) {
+		t.Fatalf("Expected %d diagnostics, got %v", len(expected), diags)
+	}
+	for i, e := range expected {
+		if diags[i].Line != e.line || diags[i].Code != e.code {
+			t.Errorf("Diagnostic %d: expected %v on line %v, got %v", i, e.code, e.line, diags[i])
+		}
+	}
+
+	// Only the good lines are applied
+	expectedData := []byte("One\x00Dva\x00T\xFDi\x00e\x00Four\x00") // ř is 0xFD in the charset
+	if !bytes.Equal(destWriter.Bytes(), expectedData) {
+		t.Errorf("Expected %q, got %q", expectedData, destWriter.Bytes())
+	}
+}
+
+func TestQPatchStringsFromReaderSkipsBlankAndComments(t *testing.T) {
+	srcData := []byte("One\x00Two\x00")
+	patchData := "; menu strings\n" +
+		"00000000-00000004: \"Jed\"\n" +
+		"\n" +
+		"   \t\r\n" +
+		"  ; Two was deleted\n" +
+		"00000004-00000008: \"Dva\"\n"
+
+	var diags shared.Diagnostics
+	var destWriter bytes.Buffer
+	err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", patchOptions{strict: true}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("Expected blank and comment lines to be skipped, got %v", diags)
+	}
+	if got := destWriter.String(); got != "Jed\x00Dva\x00" {
+		t.Errorf("Expected both lines applied, got %q", got)
+	}
+}
+
+func TestQPatchStringsFromReaderOutOfOrder(t *testing.T) {
+	srcData := []byte("One\x00Two\x00Three\x00Four\x00")
+
+	// Lines 2 and 3 both come before line 1; each is out of order, not overlapping
+	patchData := "00000008-0000000E: \"Tři\"\n" +
+		"00000000-00000004: \"Jed\"\n" +
+		"00000004-00000008: \"Dva\"\n"
+
+	var diags shared.Diagnostics
+	var destWriter bytes.Buffer
+	err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "install_exe.txt", patchOptions{strict: true}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+	if len(diags) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %v", diags)
+	}
+	for i, line := range []int{2, 3} {
+		if diags[i].Line != line || diags[i].Code != codeOutOfOrder || !strings.Contains(diags[i].Message, "line 1's") {
+			t.Errorf("Expected line %v out of order with line 1, got %v", line, diags[i])
+		}
+	}
+}
+
+func TestQPatchStringsFromReaderPascal(t *testing.T) {
+	srcData := []byte("\x00\x08New Game\x04Quit\x00")
+	patchData := "00000001-0000000a: pascal \"Nová hra\"\n" +
+		"0000000a-0000000f: pascal \"Ven\"\n"
+
+	var diags shared.Diagnostics
+	var destWriter bytes.Buffer
+	err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", patchOptions{strict: true}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+	if len(diags) != 0 {
+		t.Fatalf("Expected no diagnostics, got %v", diags)
+	}
+
+	// The length bytes follow the new text, and what's left of a range is cleared
+	expected := []byte("\x00\x08Nov\xA0 hra\x03Ven\x00\x00")
+	if out := destWriter.Bytes(); !bytes.Equal(out, expected) {
+		t.Errorf("Expected %q, got %q", expected, out)
+	}
+}
+
+func TestQPatchStringsFromReaderPascalErrors(t *testing.T) {
+	srcData := []byte("\x00\x08New Game\x04Quit\x00")
+	testCases := []struct {
+		name  string
+		patch string
+		code  string
+	}{
+		{"Too long", "0000000a-0000000f: pascal \"Konec hry\"", codeTooLong},
+		{"Not a Pascal string", "00000002-0000000a: pascal \"Nová hr\"", codeBadLength},
+		{"Prefix", "00000001-0000000a: [08] pascal \"Nová hra\"", codeBadFormat},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var diags shared.Diagnostics
+			var destWriter bytes.Buffer
+			// Pascal strings are never moved, even when strings may be
+			opts := patchOptions{strict: true, relocate: true, pack: true}
+			err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(tc.patch), "game_exe.txt", opts, &diags)
+			if err != nil {
+				t.Fatalf("qpatchStringsFromReader failed: %v", err)
+			}
+			if len(diags) != 1 || diags[0].Code != tc.code {
+				t.Errorf("Expected a %v error, got %v", tc.code, diags)
+			}
+			if !bytes.Equal(destWriter.Bytes(), srcData) {
+				t.Errorf("Expected the data to be unchanged, got %q", destWriter.Bytes())
+			}
+		})
+	}
+}
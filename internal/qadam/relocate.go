@@ -0,0 +1,239 @@
+package qadam
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/chadlyb/qadam/mz"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// span is a range of file offsets, [begin, end)
+type span struct {
+	begin, end int
+}
+
+// relocator moves strings that are too long for their place to free space in
+// the data segment, and points the code that refers to them at their new place.
+//
+// Free space is only ever reclaimed from strings of the patch: the tail left
+// over when a string gets shorter, and the whole place of a string that was
+// moved. Appending to the data segment isn't possible, since what follows the
+// initialized data in memory is zeroed at startup, and near pointers can't
+// reach anywhere else.
+type relocator struct {
+	data []byte
+	exe  *mz.File
+	ds   uint16
+	refs map[uint16][]mz.Reference
+
+	lines []*patchLine // every line of the patch, where references can't be
+	free  []span
+	trust bool // move strings even if what refers to them can't all be confirmed
+}
+
+func newRelocator(data []byte, lines []*patchLine) (*relocator, error) {
+	exe, err := mz.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	ds, _, ok := exe.DataSegment()
+	if !ok {
+		return nil, errors.New("couldn't find the data segment")
+	}
+	return &relocator{data: data, exe: exe, ds: ds, refs: exe.References(ds), lines: lines}, nil
+}
+
+// isReferenced reports whether anything may refer to an offset in s, other than the text of the patch
+func (r *relocator) isReferenced(s span) bool {
+	for at := s.begin; at < s.end; at++ {
+		off, ok := r.exe.SegOff(r.ds, at)
+		if !ok {
+			return true
+		}
+		for _, ref := range r.refs[off] {
+			if ref.Confirmed() || r.lineAt(ref) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reclaim adds s to the free space, unless something refers into it
+func (r *relocator) reclaim(s span) {
+	if s.begin >= s.end || r.isReferenced(s) {
+		return
+	}
+	r.free = append(r.free, s)
+	sort.Slice(r.free, func(i, j int) bool { return r.free[i].begin < r.free[j].begin })
+}
+
+// allocate takes size bytes of free space, returning its file offset
+func (r *relocator) allocate(size int) (int, bool) {
+	for i, s := range r.free {
+		if s.end-s.begin >= size {
+			r.free[i].begin += size
+			return s.begin, true
+		}
+	}
+	return 0, false
+}
+
+// tooLongError reports that p doesn't fit, and why it couldn't be moved
+func tooLongError(p *patchLine, reason string) *lineError {
+	return &lineError{codeTooLong, p.stringCol,
+		fmt.Errorf("string too long (%v > %v bytes), and %v", len(p.bytes)+1, p.end-p.begin, reason),
+		fmt.Sprintf("shorten the string to at most %v bytes", p.end-p.begin-1)}
+}
+
+// lowOffset is the offset below which what looks like a reference can't be told from a small number
+const lowOffset = 0x100
+
+// movable returns the references to p's string if it can be moved, or why it can't be.
+//
+// A string is only moved if every word that may refer to it is a confirmed reference,
+// of one kind, to an offset that isn't low, unless r.trust says to move it anyway.
+func (r *relocator) movable(p *patchLine) ([]mz.Reference, string) {
+	if shared.GarbagePrefixLen(r.data[p.begin:p.end-1]) > 0 {
+		return nil, "its non-text prefix may be data the code relies on, so it can't be moved"
+	}
+	off, ok := r.exe.SegOff(r.ds, int(p.begin))
+	if !ok {
+		return nil, "it isn't in the data segment, so it can't be moved"
+	}
+	var refs []mz.Reference
+	for _, ref := range r.refs[off] {
+		if !ref.Confirmed() {
+			continue
+		}
+		if other := r.lineAt(ref); other != nil {
+			return nil, fmt.Sprintf("what looked like a reference from %v is inside the string on line %v", ref, other.num)
+		}
+		refs = append(refs, ref)
+	}
+	if len(refs) == 0 {
+		return nil, "nothing refers to it that could be pointed elsewhere"
+	}
+	if r.trust {
+		return refs, ""
+	}
+
+	override := "; if you've checked that it's safe to move, build with -trust-refs"
+	if off < lowOffset {
+		return nil, fmt.Sprintf("its offset %04X is too low to tell references to it from small numbers%v", off, override)
+	}
+	if doubtful := r.doubtful(off); len(doubtful) > 0 {
+		return nil, fmt.Sprintf("%v may also refer to it, and can't be pointed elsewhere%v", describeDoubtful(doubtful), override)
+	}
+	for _, ref := range refs[1:] {
+		if ref.Kind != refs[0].Kind {
+			return nil, fmt.Sprintf("it's referred to by both %v %v and %v %v, which may not both mean it%v",
+				refs[0].Kind, refs[0], ref.Kind, ref, override)
+		}
+	}
+	return refs, ""
+}
+
+// lineAt returns the patch line whose range holds any of ref, or nil
+func (r *relocator) lineAt(ref mz.Reference) *patchLine {
+	for _, other := range r.lines {
+		if ref.At+2 > int(other.begin) && ref.At < int(other.end) {
+			return other
+		}
+	}
+	return nil
+}
+
+// doubtful returns what may refer to offset off, but can't be confirmed as a reference.
+// Words within the strings of the patch are text, and left out.
+func (r *relocator) doubtful(off uint16) []mz.Reference {
+	var doubtful []mz.Reference
+	for _, ref := range r.refs[off] {
+		if !ref.Confirmed() && r.lineAt(ref) == nil {
+			doubtful = append(doubtful, ref)
+		}
+	}
+	return doubtful
+}
+
+// describeDoubtful describes what may refer to a string, e.g. "the constant at 0000:0010 and 2 more"
+func describeDoubtful(doubtful []mz.Reference) string {
+	s := fmt.Sprintf("the %v at %v", doubtful[0].Kind, doubtful[0])
+	if len(doubtful) > 1 {
+		s += fmt.Sprintf(" and %v more", len(doubtful)-1)
+	}
+	return s
+}
+
+// moveRefs points refs, which referred to offset from, at offset to
+func (r *relocator) moveRefs(refs []mz.Reference, from, to uint16) {
+	r.takeRefs(from)
+	r.pointRefs(refs, to)
+	r.putRefs(refs, to)
+}
+
+// pointRefs rewrites refs to refer to offset to, leaving the bookkeeping to the caller
+func (r *relocator) pointRefs(refs []mz.Reference, to uint16) {
+	for _, ref := range refs {
+		binary.LittleEndian.PutUint16(r.data[ref.At:], to)
+	}
+}
+
+// takeRefs forgets the confirmed references to offset off, and returns them.
+// What can't be confirmed still refers to it, so its place isn't reclaimed.
+func (r *relocator) takeRefs(off uint16) []mz.Reference {
+	var taken, kept []mz.Reference
+	for _, ref := range r.refs[off] {
+		if ref.Confirmed() {
+			taken = append(taken, ref)
+		} else {
+			kept = append(kept, ref)
+		}
+	}
+	if len(kept) == 0 {
+		delete(r.refs, off)
+	} else {
+		r.refs[off] = kept
+	}
+	return taken
+}
+
+// putRefs records that refs refer to offset off
+func (r *relocator) putRefs(refs []mz.Reference, off uint16) {
+	if len(refs) == 0 {
+		return
+	}
+	list := append(r.refs[off], refs...)
+	sort.Slice(list, func(i, j int) bool { return list[i].At < list[j].At })
+	r.refs[off] = list
+}
+
+// relocate writes p's string to free space and rewrites the references to
+// it, returning its new offset in the data segment and the references changed.
+func (r *relocator) relocate(p *patchLine) (uint16, []mz.Reference, *lineError) {
+	refs, reason := r.movable(p)
+	if refs == nil {
+		return 0, nil, tooLongError(p, reason)
+	}
+
+	at, ok := r.allocate(len(p.bytes) + 1)
+	if !ok {
+		return 0, nil, tooLongError(p, "there's no free space for it; shorten other strings to make room")
+	}
+	newOff, ok := r.exe.SegOff(r.ds, at)
+	if !ok {
+		return 0, nil, tooLongError(p, "the free space found isn't in the data segment")
+	}
+
+	copy(r.data[at:], p.bytes)
+	r.data[at+len(p.bytes)] = 0
+	off, _ := r.exe.SegOff(r.ds, int(p.begin))
+	r.moveRefs(refs, off, newOff)
+
+	// The old place is free now
+	r.reclaim(span{int(p.begin), int(p.end)})
+	return newOff, refs, nil
+}
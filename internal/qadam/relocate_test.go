@@ -0,0 +1,283 @@
+package qadam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/mz"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// buildExe returns an MZ executable whose startup code sets DS to segment 1,
+// followed by code, and the data segment at file offset 0x30. Offsets below
+// 0100 are taken for numbers, so dataSegment is from 0001:0100, file offset 0x130.
+func buildExe(code []byte, dataSegment string) []byte {
+	image := append([]byte{0xBA, 0x01, 0x00}, code...) // mov dx, 0001
+	image = append(image, make([]byte, 16-len(image)+lowOffset)...)
+	image = append(image, dataSegment...)
+
+	size := 32 + len(image)
+	header := mz.Header{
+		LastPageBytes:    uint16(size % 512),
+		Pages:            uint16((size + 511) / 512),
+		Relocations:      1,
+		HeaderParagraphs: 2,
+		RelocOffset:      mz.HeaderSize,
+	}
+	data := header.Encode()
+	data = append(data, 0x01, 0x00, 0x00, 0x00) // relocation at 0000:0001, the immediate of mov dx
+	data = append(data, make([]byte, 32-len(data))...)
+	return append(data, image...)
+}
+
+func TestQPatchStringsFromReaderRelocate(t *testing.T) {
+	// Data segment: "Continue the game" at 0001:0100, "Quit" at 0001:0112, "Exit" at 0001:0117
+	srcData := buildExe([]byte{
+		0xB8, 0x12, 0x01, // mov ax, 0112 ("Quit")
+		0x68, 0x12, 0x01, // push 0112 ("Quit")
+		0xB8, 0x00, 0x01, // mov ax, 0100 ("Continue the game")
+	}, "Continue the game\x00Quit\x00Exit\x00")
+
+	patchData := "00000130-00000142: \"Dál\"\n" +
+		"00000142-00000147: \"Konec hry\"\n" +
+		"00000147-0000014c: \"Opustit hru\"\n"
+
+	var diags shared.Diagnostics
+	var destWriter bytes.Buffer
+	err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", patchOptions{strict: true, relocate: true}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+
+	// "Konec hry" is moved behind "Dál"; nothing refers to "Exit", so it can't be moved
+	if len(diags) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %v", diags)
+	}
+	if diags[0].Line != 2 || diags[0].Severity != shared.SeverityNote || diags[0].Code != codeRelocated {
+		t.Errorf("Expected a note about moving line 2, got %v", diags[0])
+	}
+	if diags[1].Line != 3 || diags[1].Severity != shared.SeverityError || diags[1].Code != codeTooLong {
+		t.Errorf("Expected a too-long error on line 3, got %v", diags[1])
+	}
+
+	out := destWriter.Bytes()
+	// "Dál" is 4 bytes with its NUL, so "Konec hry" goes to 0001:0104
+	if string(out[0x130:0x134]) != "D\xA0l\x00" || string(out[0x134:0x13E]) != "Konec hry\x00" {
+		t.Errorf("Unexpected data segment %q", out[0x130:])
+	}
+	if binary.LittleEndian.Uint16(out[0x24:]) != 0x0104 || binary.LittleEndian.Uint16(out[0x27:]) != 0x0104 {
+		t.Errorf("Expected references to be changed to 0104, got code % X", out[0x20:0x2C])
+	}
+	if binary.LittleEndian.Uint16(out[0x2A:]) != 0x0100 {
+		t.Errorf("Expected the reference to \"Dál\" to be unchanged, got code % X", out[0x20:0x2C])
+	}
+	if string(out[0x147:0x14C]) != "Exit\x00" {
+		t.Errorf("Expected \"Exit\" to be unchanged, got %q", out[0x147:0x14C])
+	}
+}
+
+func TestQPatchStringsFromReaderRelocateNeedsSpace(t *testing.T) {
+	srcData := buildExe([]byte{0xB8, 0x04, 0x01}, "Yes\x00No\x00")
+	patchData := "00000130-00000134: \"Ano\"\n00000134-00000137: \"Nikoliv\"\n"
+
+	var diags shared.Diagnostics
+	var destWriter bytes.Buffer
+	err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", patchOptions{strict: true, relocate: true}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+	if len(diags) != 1 || diags[0].Code != codeTooLong || !strings.Contains(diags[0].Message, "no free space") {
+		t.Errorf("Expected a too-long error for lack of space, got %v", diags)
+	}
+	if binary.LittleEndian.Uint16(destWriter.Bytes()[0x24:]) != 0x0104 {
+		t.Error("Expected the reference to be unchanged")
+	}
+}
+
+func TestQPatchStringsFromReaderPack(t *testing.T) {
+	// Data segment: "Yes" at 0001:0100, "No" at 0001:0104, "Cancel" at 0001:0107
+	srcData := buildExe([]byte{
+		0xB8, 0x00, 0x01, // mov ax, 0100 ("Yes")
+		0xB8, 0x04, 0x01, // mov ax, 0104 ("No")
+		0x68, 0x07, 0x01, // push 0107 ("Cancel")
+	}, "Yes\x00No\x00Cancel\x00")
+
+	patchData := "00000130-00000134: \"A\"\n" +
+		"00000134-00000137: \"Nikoliv\"\n" +
+		"00000137-0000013e: \"Zr\"\n"
+
+	var diags shared.Diagnostics
+	var destWriter bytes.Buffer
+	err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", patchOptions{strict: true, pack: true}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+
+	// "A" stays put; "Nikoliv" and "Zr" follow it
+	if len(diags) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %v", diags)
+	}
+	for i, line := range []int{2, 3} {
+		if diags[i].Line != line || diags[i].Severity != shared.SeverityNote || diags[i].Code != codePacked {
+			t.Errorf("Expected a note about packing line %v, got %v", line, diags[i])
+		}
+	}
+	if !strings.Contains(diags[0].Message, "0001:0104 to 0001:0102") || !strings.Contains(diags[0].Message, "0000:0007") {
+		t.Errorf("Expected the note to give the move and the reference changed, got %q", diags[0].Message)
+	}
+
+	out := destWriter.Bytes()
+	if string(out[0x130:0x13E]) != "A\x00Nikoliv\x00Zr\x00\x00" {
+		t.Errorf("Unexpected data segment %q", out[0x130:])
+	}
+	for at, want := range map[int]uint16{0x24: 0x0100, 0x27: 0x0102, 0x2A: 0x010A} {
+		if got := binary.LittleEndian.Uint16(out[at:]); got != want {
+			t.Errorf("Expected reference at %X to be %04X, got %04X", at, want, got)
+		}
+	}
+}
+
+func TestQPatchStringsFromReaderPackNoRoom(t *testing.T) {
+	srcData := buildExe([]byte{0xB8, 0x04, 0x01, 0xB8, 0x07, 0x01}, "Yes\x00No\x00Cancel\x00")
+	patchData := "00000134-00000137: \"Nikoliv\"\n00000137-0000013e: \"Zrusit\"\n"
+
+	var diags shared.Diagnostics
+	var destWriter bytes.Buffer
+	err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", patchOptions{strict: true, pack: true}, &diags)
+	if err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+	if len(diags) != 1 || diags[0].Code != codeTooLong || !strings.Contains(diags[0].Message, "no room to spare") {
+		t.Errorf("Expected a too-long error for lack of room, got %v", diags)
+	}
+	if string(destWriter.Bytes()[0x134:0x13E]) != "No\x00Zrusit\x00" {
+		t.Errorf("Expected only the string that fits to be written, got %q", destWriter.Bytes()[0x134:0x13E])
+	}
+}
+
+func TestPackIntoNeighbour(t *testing.T) {
+	// "Anoano" grows over "No", which moves to where "Cancel" was
+	data := buildExe([]byte{
+		0xB8, 0x00, 0x01, // mov ax, 0100 ("Yes")
+		0xB8, 0x04, 0x01, // mov ax, 0104 ("No")
+		0x68, 0x07, 0x01, // push 0107 ("Cancel")
+	}, "Yes\x00No\x00Cancel\x00")
+	var lines []*patchLine
+	for i, text := range []string{
+		"00000130-00000134: \"Anoano\"",
+		"00000134-00000137: \"Ne\"",
+		"00000137-0000013e: \"Zr\"",
+	} {
+		p, lerr := parseLine(i+1, text)
+		if lerr == nil {
+			lerr = p.check(data)
+		}
+		if lerr != nil && lerr.code == codeTooLong {
+			p.tooLong, lerr = true, nil
+		}
+		if lerr != nil {
+			t.Fatalf("Line %v: %v", i+1, lerr.err)
+		}
+		if !p.tooLong {
+			p.apply(data)
+		}
+		lines = append(lines, p)
+	}
+
+	r, err := newRelocator(data, lines)
+	if err != nil {
+		t.Fatalf("newRelocator failed: %v", err)
+	}
+	if moved := r.pack(lines); len(moved) != 2 {
+		t.Fatalf("Expected 2 strings moved, got %v", moved)
+	}
+	if string(data[0x130:0x13E]) != "Anoano\x00Ne\x00Zr\x00\x00" {
+		t.Errorf("Unexpected data segment %q", data[0x130:])
+	}
+	for off, at := range map[uint16]int{0x0100: 0x24, 0x0107: 0x27, 0x010A: 0x2A} {
+		if refs := r.refs[off]; len(refs) != 1 || refs[0].At != at {
+			t.Errorf("Expected the reference to %04X at %X, got %v", off, at, refs)
+		}
+	}
+	if refs := r.refs[0x0104]; len(refs) != 0 {
+		t.Errorf("Expected nothing to refer to 0104 any more, got %v", refs)
+	}
+}
+
+func TestQPatchStringsFromReaderRelocateAmbiguous(t *testing.T) {
+	patchData := "00000130-00000142: \"Dál\"\n00000142-00000147: \"Konec hry\"\n"
+	tests := []struct {
+		name  string
+		code  []byte
+		data  string
+		trust bool
+		msg   string // of the too-long error, or "" if the string is moved
+	}{
+		{"confirmed", []byte{0xB8, 0x12, 0x01}, "", false, ""},
+		{"call", []byte{0xB8, 0x12, 0x01, 0xE8, 0x12, 0x01}, "", false, ""},
+		{"constant", []byte{0xB8, 0x12, 0x01, 0x3D, 0x12, 0x01}, "", false, "the constant at 0000:0007 may also refer to it"},
+		{"address", []byte{0xB8, 0x12, 0x01, 0xA0, 0x12, 0x01}, "", false, "the address at 0000:0007 may also refer to it"},
+		{"near pointer", []byte{0xB8, 0x12, 0x01}, "\x12\x01", false, "the data at 0001:0117 may also refer to it"},
+		{"trusted", []byte{0xB8, 0x12, 0x01, 0x3D, 0x12, 0x01}, "", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srcData := buildExe(tt.code, "Continue the game\x00Quit\x00"+tt.data)
+			var diags shared.Diagnostics
+			var destWriter bytes.Buffer
+			opts := patchOptions{strict: true, relocate: true, trustRefs: tt.trust}
+			if err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", opts, &diags); err != nil {
+				t.Fatalf("qpatchStringsFromReader failed: %v", err)
+			}
+			if len(diags) != 1 || diags[0].Line != 2 {
+				t.Fatalf("Expected 1 diagnostic on line 2, got %v", diags)
+			}
+			if tt.msg == "" {
+				if diags[0].Code != codeRelocated {
+					t.Errorf("Expected the string to be moved, got %v", diags[0])
+				}
+				if tt.trust && !strings.Contains(diags[0].Message, "the constant at 0000:0007 may also refer to its old place") {
+					t.Errorf("Expected the note to give what wasn't changed, got %q", diags[0].Message)
+				}
+				return
+			}
+			if diags[0].Code != codeTooLong || !strings.Contains(diags[0].Message, tt.msg) || !strings.Contains(diags[0].Message, "-trust-refs") {
+				t.Errorf("Expected a too-long error saying %q, got %v", tt.msg, diags[0])
+			}
+			if binary.LittleEndian.Uint16(destWriter.Bytes()[0x24:]) != 0x0112 {
+				t.Error("Expected the reference to be unchanged")
+			}
+		})
+	}
+}
+
+func TestQPatchStringsFromReaderRelocateLowOffset(t *testing.T) {
+	// mov ax, 0004 may be the number 4 as well as the offset of "No" at 0001:0004
+	srcData := buildExe([]byte{0xB8, 0x04, 0x00}, "Continue the game\x00")
+	copy(srcData[0x34:], "No\x00")
+	patchData := "00000034-00000037: \"Nikoliv\"\n00000130-00000142: \"Dál\"\n"
+
+	for _, trust := range []bool{false, true} {
+		var diags shared.Diagnostics
+		var destWriter bytes.Buffer
+		opts := patchOptions{strict: true, relocate: true, trustRefs: trust}
+		if err := qpatchStringsFromReader(bytes.NewReader(srcData), &destWriter, strings.NewReader(patchData), "game_exe.txt", opts, &diags); err != nil {
+			t.Fatalf("qpatchStringsFromReader failed: %v", err)
+		}
+		if len(diags) != 1 {
+			t.Fatalf("Expected 1 diagnostic, got %v", diags)
+		}
+		got := binary.LittleEndian.Uint16(destWriter.Bytes()[0x24:])
+		if trust {
+			if diags[0].Code != codeRelocated || got != 0x0104 {
+				t.Errorf("Expected the string to be moved to 0104 when trusted, got %v and %04X", diags[0], got)
+			}
+			continue
+		}
+		if diags[0].Code != codeTooLong || !strings.Contains(diags[0].Message, "offset 0004 is too low") || got != 0x0004 {
+			t.Errorf("Expected a too-long error for the low offset, got %v and %04X", diags[0], got)
+		}
+	}
+}
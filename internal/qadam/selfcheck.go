@@ -0,0 +1,302 @@
+package qadam
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chadlyb/qadam/fil"
+	"github.com/chadlyb/qadam/mz"
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// codeSelfCheck is the code of problems build finds in the files it wrote
+const codeSelfCheck = "self-check"
+
+// selfCheck reads back the files build wrote to outputDir from the extracted directory srcPath, and
+// checks that every string reads back as written in its text file, that the sections of data files
+// are where their directories say, that the size fields written to the executable sizeExe at sizeOffsets
+// match the built files, and that executables differ from the originals only where build may change them.
+// Problems are added to diags as errors.
+func selfCheck(srcPath, outputDir string, files []project.File, sizeExe string, sizes []sizeField, sizeOffsets []int, diags *shared.Diagnostics) error {
+	before := diags.Count(shared.SeverityError)
+	for _, f := range files {
+		text, err := os.ReadFile(filepath.Join(srcPath, f.Text))
+		if err != nil {
+			return fmt.Errorf("couldn't read %v: %w", f.Text, err)
+		}
+		og, err := os.ReadFile(filepath.Join(srcPath, "og", f.Name))
+		if err != nil {
+			return fmt.Errorf("couldn't read original %v: %w", f.Name, err)
+		}
+		built, err := os.ReadFile(filepath.Join(outputDir, f.Name))
+		if err != nil {
+			return fmt.Errorf("couldn't read built %v: %w", f.Name, err)
+		}
+
+		file := filepath.Base(f.Text)
+		switch f.Codec {
+		case project.CodecFIL:
+			checkFIL(file, f.Name, text, og, built, diags)
+		case project.CodecEXE:
+			var fixed []span
+			if strings.EqualFold(f.Name, sizeExe) {
+				for _, at := range sizeOffsets {
+					fixed = append(fixed, span{at, at + 4})
+				}
+			}
+			checkEXE(file, f.Name, text, og, built, fixed, diags)
+		}
+	}
+
+	if len(sizes) > 0 {
+		exe, err := os.ReadFile(filepath.Join(outputDir, sizeExe))
+		if err != nil {
+			return fmt.Errorf("couldn't read built %v: %w", sizeExe, err)
+		}
+		for i, s := range sizes {
+			info, err := os.Stat(s.newPath)
+			if err != nil {
+				return fmt.Errorf("couldn't get %v size: %w", s.name, err)
+			}
+			if got := binary.LittleEndian.Uint32(exe[sizeOffsets[i]:]); int64(got) != info.Size() {
+				selfCheckFailed(diags, sizeExe, 0, "%v size field at 0x%X holds %v, but the built %v is %v bytes",
+					s.name, sizeOffsets[i], got, s.name, info.Size())
+			}
+		}
+	}
+
+	if n := diags.Count(shared.SeverityError) - before; n > 0 {
+		return fmt.Errorf("the built files failed %v self-check(s)", n)
+	}
+	return nil
+}
+
+// selfCheckFailed adds a problem with the built files to diags
+func selfCheckFailed(diags *shared.Diagnostics, file string, line int, format string, args ...any) {
+	diags.Add(shared.Diagnostic{
+		File:     file,
+		Line:     line,
+		Severity: shared.SeverityError,
+		Code:     codeSelfCheck,
+		Message:  fmt.Sprintf(format, args...),
+		Fix:      "this is a bug in build; please report it along with the text file",
+	})
+}
+
+// checkFIL checks built, the .FIL file named name as built from its text form text, called file,
+// and its original og
+func checkFIL(file, name string, text, og, built []byte, diags *shared.Diagnostics) {
+	parsed, err := fil.ParseText(bytes.NewReader(text))
+	if err != nil {
+		selfCheckFailed(diags, file, 0, "couldn't parse it again: %v", err)
+		return
+	}
+	ogFile, err := fil.DecodeWithLayouts(og, fil.LayoutsFor(name))
+	if err != nil {
+		selfCheckFailed(diags, file, 0, "couldn't decode the original %v: %v", name, err)
+		return
+	}
+
+	// Kept sections are the original's
+	want := make([]fil.Section, len(parsed.Sections))
+	layouts := map[int]fil.Layout{}
+	for i, s := range parsed.Sections {
+		want[i] = s
+		if s.Layout.Kind == fil.Kept {
+			if i >= len(ogFile.Sections) {
+				selfCheckFailed(diags, file, s.Pos.Line, "section %v is kept, but the original %v has only %v", i, name, len(ogFile.Sections))
+				return
+			}
+			want[i] = ogFile.Sections[i]
+		}
+		layouts[i] = want[i].Layout
+	}
+
+	// The directory must point at each section as written
+	dirSize := 1 + (len(want)+1)*3
+	if len(built) < dirSize || int(built[0]) != len(want) {
+		selfCheckFailed(diags, file, 0, "the built %v doesn't start with a directory of %v sections", name, len(want))
+		return
+	}
+	at := dirSize
+	for i, s := range want {
+		if off := int24(built, 1+i*3); off != at {
+			selfCheckFailed(diags, file, s.Pos.Line, "section %v of the built %v should start at 0x%X, but its directory says 0x%X", i, name, at, off)
+			return
+		}
+		size, err := sectionSize(s)
+		if err != nil {
+			selfCheckFailed(diags, file, s.Pos.Line, "couldn't encode section %v: %v", i, err)
+			return
+		}
+		at += size
+	}
+	if total := int24(built, 1+len(want)*3); total != at || len(built) != at {
+		selfCheckFailed(diags, file, 0, "the built %v is %v bytes, its directory says %v, and its sections add up to %v", name, len(built), total, at)
+		return
+	}
+
+	got, err := fil.DecodeWithLayouts(built, layouts)
+	if err != nil {
+		selfCheckFailed(diags, file, 0, "the built %v doesn't decode: %v", name, err)
+		return
+	}
+	for i, s := range want {
+		checkSection(file, name, i, s, got.Sections[i], diags)
+	}
+}
+
+// checkSection checks section i of the built .FIL file named name reads back as got the same as want
+func checkSection(file, name string, i int, want, got fil.Section, diags *shared.Diagnostics) {
+	if want.Layout.Kind == fil.Binary {
+		if !bytes.Equal(want.Data, got.Data) {
+			selfCheckFailed(diags, file, want.Pos.Line, "binary section %v of the built %v doesn't read back as written", i, name)
+		}
+		return
+	}
+	if len(want.Records) != len(got.Records) {
+		selfCheckFailed(diags, file, want.Pos.Line, "section %v of the built %v reads back as %v records, not %v",
+			i, name, len(got.Records), len(want.Records))
+		return
+	}
+	for j, w := range want.Records {
+		g := got.Records[j]
+		if !bytes.Equal(w.Header, g.Header) || w.NoNul != g.NoNul || !sameText(w.Text, g.Text) {
+			selfCheckFailed(diags, file, w.Pos.Line, "record %v of section %v reads back from the built %v as [%X] \"%v\", not [%X] \"%v\"",
+				j, i, name, g.Header, fil.EscapeString(g.Text), w.Header, fil.EscapeString(w.Text))
+			return
+		}
+	}
+}
+
+// sameText reports whether strings a and b are the same in the game's charset
+func sameText(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ea, erra := fil.EncodeString(a)
+	eb, errb := fil.EncodeString(b)
+	return erra == nil && errb == nil && bytes.Equal(ea, eb)
+}
+
+// sectionSize returns how many bytes section s takes once encoded
+func sectionSize(s fil.Section) (int, error) {
+	if s.Layout.Kind == fil.Binary {
+		return len(s.Data), nil
+	}
+	size := 0
+	for _, r := range s.Records {
+		enc, err := r.Encode()
+		if err != nil {
+			return 0, err
+		}
+		size += len(enc)
+	}
+	return size, nil
+}
+
+// int24 reads the little-endian 24-bit number at data[at:]
+func int24(data []byte, at int) int {
+	return int(data[at]) | int(data[at+1])<<8 | int(data[at+2])<<16
+}
+
+// checkEXE checks built, the executable named name as patched with the patch file text, called file,
+// against its original og. Only the ranges of the lines build applied, the references to strings
+// it moved, and the spans fixed may differ from og.
+func checkEXE(file, name string, text, og, built []byte, fixed []span, diags *shared.Diagnostics) {
+	if len(built) != len(og) {
+		selfCheckFailed(diags, file, 0, "the built %v is %v bytes, but the original is %v", name, len(built), len(og))
+		return
+	}
+
+	// Lines build found a problem with were skipped
+	skipped := map[int]bool{}
+	for _, d := range *diags {
+		if d.File == file && d.Severity != shared.SeverityNote {
+			skipped[d.Line] = true
+		}
+	}
+
+	// References to the strings, to follow those that were moved
+	var exe *mz.File
+	var ds uint16
+	var refs map[uint16][]mz.Reference
+	if parsed, err := mz.Parse(og); err == nil {
+		if seg, _, ok := parsed.DataSegment(); ok {
+			exe, ds, refs = parsed, seg, parsed.References(seg)
+		}
+	}
+
+	changeable := make([]bool, len(og))
+	allow := func(s span) {
+		for at := max(s.begin, 0); at < s.end && at < len(changeable); at++ {
+			changeable[at] = true
+		}
+	}
+	for _, s := range fixed {
+		allow(s)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for num := 1; scanner.Scan(); num++ {
+		p, lerr := parseLine(num, scanner.Text())
+		if lerr != nil || skipped[num] || p.begin >= p.end || p.end > uint64(len(og)) {
+			continue
+		}
+		allow(span{int(p.begin), int(p.end)})
+
+		// The string is wherever its references point now
+		places := map[int]bool{int(p.begin): true}
+		if exe != nil {
+			if off, ok := exe.SegOff(ds, int(p.begin)); ok && len(confirmed(refs[off])) > 0 {
+				places = map[int]bool{}
+				for _, ref := range confirmed(refs[off]) {
+					allow(span{ref.At, ref.At + 2})
+					places[exe.FileOffset(ds, binary.LittleEndian.Uint16(built[ref.At:]))] = true
+				}
+			}
+		}
+		for at := range places {
+			checkString(file, name, p, built, at, diags)
+		}
+	}
+
+	for at := range og {
+		if og[at] != built[at] && !changeable[at] {
+			selfCheckFailed(diags, file, 0, "the built %v differs from the original at 0x%X, outside the strings of %v", name, at, file)
+			return
+		}
+	}
+}
+
+// confirmed returns the references of refs that are confirmed, which are the ones build may change
+func confirmed(refs []mz.Reference) []mz.Reference {
+	var list []mz.Reference
+	for _, ref := range refs {
+		if ref.Confirmed() {
+			list = append(list, ref)
+		}
+	}
+	return list
+}
+
+// checkString checks the string of patch line p reads back from built, the executable named name, at offset at
+func checkString(file, name string, p *patchLine, built []byte, at int, diags *shared.Diagnostics) {
+	n := len(p.bytes)
+	ok := false
+	if p.pascal {
+		ok = at >= 0 && at+1+n <= len(built) && int(built[at]) == n && bytes.Equal(built[at+1:at+1+n], p.bytes)
+	} else {
+		ok = at >= 0 && at+n < len(built) && bytes.Equal(built[at:at+n], p.bytes) && built[at+n] == 0
+	}
+	if !ok {
+		got := built[min(max(at, 0), len(built)):min(max(at+n+1, 0), len(built))]
+		selfCheckFailed(diags, file, p.num, "the string reads back from the built %v at 0x%X as [% X], not as written", name, at, got)
+	}
+}
@@ -0,0 +1,139 @@
+package qadam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+func TestCheckFIL(t *testing.T) {
+	game := t.TempDir()
+	writeGame(t, game)
+	og, err := os.ReadFile(filepath.Join(game, "TEXTS.FIL"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := []byte("SECTION 0\n; the greeting\n[01 02 03 04 05] \"Ahoj\"\n")
+	var diags shared.Diagnostics
+	built, err := processFile(bytes.NewReader(text), og, "texts.txt", true, &diags)
+	if err != nil || built == nil {
+		t.Fatalf("processFile failed: %v %v", err, diags)
+	}
+
+	tests := []struct {
+		name   string
+		change func(b []byte) []byte
+		line   int
+		msg    string
+	}{
+		{"same", func(b []byte) []byte { return b }, 0, ""},
+		{"string", func(b []byte) []byte { b[13]++; return b }, 3, "record 0 of section 0 reads back from the built TEXTS.FIL as"},
+		{"directory", func(b []byte) []byte { b[1]++; return b }, 1, "section 0 of the built TEXTS.FIL should start at 0x7, but its directory says 0x8"},
+		{"size", func(b []byte) []byte { return append(b, 0) }, 0, "the built TEXTS.FIL is 18 bytes, its directory says 17"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var diags shared.Diagnostics
+			checkFIL("texts.txt", "TEXTS.FIL", text, og, tt.change(append([]byte(nil), built...)), &diags)
+			if tt.msg == "" {
+				if len(diags) != 0 {
+					t.Errorf("Expected no problems, got %v", diags)
+				}
+				return
+			}
+			if len(diags) != 1 {
+				t.Fatalf("Expected 1 problem, got %v", diags)
+			}
+			d := diags[0]
+			if d.Code != codeSelfCheck || d.Severity != shared.SeverityError || d.Line != tt.line || !strings.Contains(d.Message, tt.msg) {
+				t.Errorf("Expected a self-check error on line %v saying %q, got %v", tt.line, tt.msg, d)
+			}
+		})
+	}
+}
+
+func TestCheckEXE(t *testing.T) {
+	// As in TestQPatchStringsFromReaderRelocate: "Konec hry" is moved behind "Dál"
+	og := buildExe([]byte{
+		0xB8, 0x12, 0x01, // mov ax, 0112 ("Quit")
+		0x68, 0x12, 0x01, // push 0112 ("Quit")
+		0xB8, 0x00, 0x01, // mov ax, 0100 ("Continue the game")
+	}, "Continue the game\x00Quit\x00Exit\x00")
+	text := []byte("00000130-00000142: \"Dál\"\n" +
+		"00000142-00000147: \"Konec hry\"\n" +
+		"00000147-0000014c: \"Konec\"\n")
+
+	var patched shared.Diagnostics
+	var out bytes.Buffer
+	if err := qpatchStringsFromReader(bytes.NewReader(og), &out, bytes.NewReader(text), "game_exe.txt", patchOptions{relocate: true}, &patched); err != nil {
+		t.Fatalf("qpatchStringsFromReader failed: %v", err)
+	}
+	built := out.Bytes()
+
+	tests := []struct {
+		name   string
+		change func(b []byte) []byte
+		fixed  []span
+		line   int
+		msg    string
+	}{
+		{"same", func(b []byte) []byte { return b }, nil, 0, ""},
+		{"moved", func(b []byte) []byte { b[0x135] = 'X'; return b }, nil, 2, "reads back from the built GAME.EXE at 0x134"},
+		{"in place", func(b []byte) []byte { b[0x130] = 'X'; return b }, nil, 1, "reads back from the built GAME.EXE at 0x130"},
+		{"code", func(b []byte) []byte { b[0x29] = 0x90; return b }, nil, 0, "differs from the original at 0x29, outside the strings"},
+		{"fixed", func(b []byte) []byte { b[0x29] = 0x90; return b }, []span{{0x29, 0x2A}}, 0, ""},
+		{"length", func(b []byte) []byte { return b[:len(b)-1] }, nil, 0, "is 331 bytes, but the original is 332"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := append(shared.Diagnostics(nil), patched...)
+			checkEXE("game_exe.txt", "GAME.EXE", text, og, tt.change(append([]byte(nil), built...)), tt.fixed, &diags)
+			diags = diags[len(patched):]
+			if tt.msg == "" {
+				if len(diags) != 0 {
+					t.Errorf("Expected no problems, got %v", diags)
+				}
+				return
+			}
+			if len(diags) != 1 {
+				t.Fatalf("Expected 1 problem, got %v", diags)
+			}
+			d := diags[0]
+			if d.Code != codeSelfCheck || d.Line != tt.line || !strings.Contains(d.Message, tt.msg) {
+				t.Errorf("Expected a self-check error on line %v saying %q, got %v", tt.line, tt.msg, d)
+			}
+		})
+	}
+}
+
+func TestSelfCheckSizeFields(t *testing.T) {
+	srcPath := writeExtracted(t)
+	outputDir := filepath.Join(t.TempDir(), "built")
+	var diags shared.Diagnostics
+	if err := build(srcPath, outputDir, project.DefaultFiles, buildOptions{lint: true, strict: true}, &diags); err != nil {
+		t.Fatalf("build failed: %v %v", err, diags)
+	}
+
+	// GAME.EXE of writeGame keeps the size of TEXTS.FIL at 0x04
+	exePath := filepath.Join(outputDir, "GAME.EXE")
+	exe, err := os.ReadFile(exePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(exe[0x04:], 15)
+	if err := os.WriteFile(exePath, exe, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := []sizeField{{"TEXTS.FIL", filepath.Join(srcPath, "og", "TEXTS.FIL"), filepath.Join(outputDir, "TEXTS.FIL"), 0}}
+	err = selfCheck(srcPath, outputDir, project.DefaultFiles[:1], "GAME.EXE", sizes, []int{0x04}, &diags)
+	if err == nil || len(diags) != 1 || !strings.Contains(diags[0].Message, "TEXTS.FIL size field at 0x4 holds 15, but the built TEXTS.FIL is 17 bytes") {
+		t.Errorf("Expected a size field problem, got %v %v", err, diags)
+	}
+}
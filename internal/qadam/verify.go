@@ -0,0 +1,179 @@
+package qadam
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chadlyb/qadam/edition"
+	"github.com/chadlyb/qadam/fil"
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+// hexContext is how many bytes around a difference verify shows
+const hexContext = 8
+
+// verify decodes each of files of the game in gamePath to text and builds it again, unchanged
+// and in memory, and checks it comes out the same as the original. Problems with the text
+// are added to diags; files that come out different are reported in the error.
+func verify(gamePath string, files []project.File, diags *shared.Diagnostics) error {
+	ed, err := edition.Check(gamePath, false, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, f := range files {
+		og, err := os.ReadFile(filepath.Join(gamePath, f.Name))
+		if err != nil {
+			return fmt.Errorf("couldn't read %v: %w", f.Name, err)
+		}
+		text, got, err := roundTrip(f, og, stringRange(ed, f.Name), diags)
+		if err != nil {
+			return fmt.Errorf("couldn't round-trip %v: %w", f.Name, err)
+		}
+		if got == nil {
+			continue // the problems are in diags
+		}
+		if err := divergence(f, og, got, text); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// roundTrip decodes file f, whose contents are og, to its text form as extract does, and builds it
+// back as build does. It returns the text and the result, which is nil if the text has problems.
+func roundTrip(f project.File, og []byte, within edition.Range, diags *shared.Diagnostics) ([]byte, []byte, error) {
+	var text, out bytes.Buffer
+	switch f.Codec {
+	case project.CodecFIL:
+		if err := qdecompFileFromReader(bytes.NewReader(og), &text, f.Name); err != nil {
+			return nil, nil, err
+		}
+		got, err := processFile(bytes.NewReader(text.Bytes()), og, f.Text, true, diags)
+		return text.Bytes(), got, err
+	case project.CodecEXE:
+		if err := qgetStringsFromReader(bytes.NewReader(og), &text, false, within); err != nil {
+			return nil, nil, err
+		}
+		before := diags.Count(shared.SeverityError)
+		err := qpatchStringsFromReader(bytes.NewReader(og), &out, bytes.NewReader(text.Bytes()), f.Text, patchOptions{strict: true}, diags)
+		if err != nil || diags.Count(shared.SeverityError) > before {
+			return text.Bytes(), nil, err
+		}
+		return text.Bytes(), out.Bytes(), nil
+	}
+	return nil, nil, fmt.Errorf("unknown codec '%v'", f.Codec)
+}
+
+// divergence reports where got, file f as built from its text form text, first differs from
+// its original want, with what's there and the bytes around it. It returns nil if they're the same.
+func divergence(f project.File, want, got, text []byte) error {
+	if bytes.Equal(want, got) {
+		return nil
+	}
+	at := 0
+	for at < len(want) && at < len(got) && want[at] == got[at] {
+		at++
+	}
+
+	where := ""
+	switch f.Codec {
+	case project.CodecFIL:
+		where = recordAt(f.Name, want, at)
+	case project.CodecEXE:
+		where = patchLineAt(f.Text, text, at)
+	}
+	return fmt.Errorf("%v differs from the original at offset 0x%X (%v bytes, originally %v), %v\n  original: %v\n  rebuilt:  %v",
+		f.Name, at, len(got), len(want), where, hexAround(want, at), hexAround(got, at))
+}
+
+// recordAt describes what's at offset at of the .FIL file named name, whose contents are data
+func recordAt(name string, data []byte, at int) string {
+	ff, err := fil.DecodeWithLayouts(data, fil.LayoutsFor(name))
+	if err != nil {
+		return "which doesn't decode"
+	}
+	offset := 1 + (len(ff.Sections)+1)*3
+	if at < offset {
+		return "in the directory"
+	}
+	for i, s := range ff.Sections {
+		if s.Layout.Kind == fil.Binary {
+			if at < offset+len(s.Data) {
+				return fmt.Sprintf("in binary section %v", i)
+			}
+			offset += len(s.Data)
+			continue
+		}
+		for j, r := range s.Records {
+			enc, err := r.Encode()
+			if err != nil {
+				return fmt.Sprintf("in section %v, from record %v", i, j)
+			}
+			if at < offset+len(enc) {
+				return fmt.Sprintf("in section %v record %v [%X] \"%v\"", i, j, r.Header, fil.EscapeString(r.Text))
+			}
+			offset += len(enc)
+		}
+	}
+	return "past the end"
+}
+
+// patchLineAt describes the line of the patch file called file, whose contents are text, that covers offset at
+func patchLineAt(file string, text []byte, at int) string {
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for num := 1; scanner.Scan(); num++ {
+		p, lerr := parseLine(num, scanner.Text())
+		if lerr == nil && uint64(at) >= p.begin && uint64(at) < p.end {
+			return fmt.Sprintf("in %v line %v: %v", file, num, scanner.Text())
+		}
+	}
+	return "outside the strings of " + file
+}
+
+// hexAround shows the bytes of data around offset at, with the one at at in brackets
+func hexAround(data []byte, at int) string {
+	begin, end := max(at-hexContext, 0), min(at+hexContext+1, len(data))
+	var b strings.Builder
+	fmt.Fprintf(&b, "%06X:", begin)
+	for i := begin; i < end; i++ {
+		if i == at {
+			fmt.Fprintf(&b, " [%02X]", data[i])
+		} else {
+			fmt.Fprintf(&b, " %02X", data[i])
+		}
+	}
+	if at >= len(data) {
+		b.WriteString(" [end]")
+	}
+	return b.String()
+}
+
+// setupVerify adds the flags of verify to fs
+func setupVerify(fs *flag.FlagSet) runFunc {
+	return func(args []string, diags *shared.Diagnostics) error {
+		gamePath, files := args[0], project.DefaultFiles
+		p, err := openProject(args[0])
+		if err != nil {
+			return err
+		}
+		if p != nil {
+			gamePath, files = p.Path(p.Game), p.Files
+		}
+		if err := verify(gamePath, files, diags); err != nil {
+			return err
+		}
+		if !diags.HasErrors() {
+			fmt.Println("INFO: Decoding and building again gives back the original files")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package qadam
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chadlyb/qadam/project"
+	"github.com/chadlyb/qadam/shared"
+)
+
+func TestVerify(t *testing.T) {
+	game := t.TempDir()
+	writeGame(t, game)
+	var diags shared.Diagnostics
+	if err := verify(game, project.DefaultFiles, &diags); err != nil || diags.HasErrors() {
+		t.Errorf("verify failed: %v %v", err, diags)
+	}
+
+	if err := os.Remove(filepath.Join(game, "INSTALL.EXE")); err != nil {
+		t.Fatal(err)
+	}
+	if err := verify(game, project.DefaultFiles, &diags); err == nil || !strings.Contains(err.Error(), "INSTALL.EXE") {
+		t.Errorf("Expected an error naming INSTALL.EXE, got %v", err)
+	}
+}
+
+func TestDivergence(t *testing.T) {
+	game := t.TempDir()
+	writeGame(t, game)
+	read := func(name string) []byte {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(game, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	texts, exe := read("TEXTS.FIL"), read("GAME.EXE")
+	patch := []byte("00000021-00000035: \"Welcome to the game\"\n")
+
+	tests := []struct {
+		name string
+		file project.File
+		want []byte
+		got  func([]byte) []byte
+		text []byte
+		msg  []string
+	}{
+		{"same", project.DefaultFiles[0], texts, func(b []byte) []byte { return b }, nil, nil},
+		{"record", project.DefaultFiles[0], texts, func(b []byte) []byte { b[13] = 0x7A; return b }, nil,
+			[]string{"TEXTS.FIL differs from the original at offset 0xD", "section 0 record 0 [0102030405] \"Hi\"",
+				"original: 000005: 00 00 01 02 03 04 05 79 [9A] 00", "rebuilt:  000005: 00 00 01 02 03 04 05 79 [7A] 00"}},
+		{"directory", project.DefaultFiles[0], texts, func(b []byte) []byte { b[4] = 0x10; return b }, nil,
+			[]string{"offset 0x4", "in the directory"}},
+		{"shorter", project.DefaultFiles[0], texts, func(b []byte) []byte { return b[:14] }, nil,
+			[]string{"offset 0xE (14 bytes, originally 15)", "[end]"}},
+		{"string", project.DefaultFiles[2], exe, func(b []byte) []byte { b[0x22] = 'w'; return b }, patch,
+			[]string{"GAME.EXE differs", "offset 0x22", "in game_exe.txt line 1: 00000021-00000035"}},
+		{"outside", project.DefaultFiles[2], exe, func(b []byte) []byte { b[0x04] = 0; return b }, patch,
+			[]string{"offset 0x4", "outside the strings of game_exe.txt"}},
+		{"last", project.DefaultFiles[2], exe, func(b []byte) []byte { b[0x34] = 1; return b }, patch,
+			[]string{"offset 0x34", "in game_exe.txt line 1"}},
+		{"past the end", project.DefaultFiles[2], exe, func(b []byte) []byte { b[0x21] = 1; return b }, []byte("00000000-00000021: \"x\"\n"),
+			[]string{"offset 0x21", "outside the strings of game_exe.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.got(append([]byte(nil), tt.want...))
+			err := divergence(tt.file, tt.want, got, tt.text)
+			if tt.msg == nil {
+				if err != nil {
+					t.Errorf("Expected no difference, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected a difference")
+			}
+			for _, m := range tt.msg {
+				if !strings.Contains(err.Error(), m) {
+					t.Errorf("Expected %q in:\n%v", m, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package mz
+
+// operand is a 16-bit operand of a decoded instruction that may hold an offset
+type operand struct {
+	at   int // offset of the operand in the instruction
+	kind Kind
+}
+
+// instruction is the length and offset operands of a decoded instruction
+type instruction struct {
+	length   int
+	operands []operand
+}
+
+// decoder decodes one instruction of real mode code
+type decoder struct {
+	code   []byte
+	at     int
+	wide   bool // 32-bit operands (66 prefix)
+	addr32 bool // 32-bit addressing (67 prefix)
+	ops    []operand
+}
+
+// decode decodes the instruction at code[at:], as found in 16-bit real mode
+// code for processors up to the 386, and reports whether it could. Only the
+// lengths of instructions and where their 16-bit immediates and addresses
+// are matter, so most opcodes are only told apart by their form.
+func decode(code []byte, at int) (instruction, bool) {
+	d := &decoder{code: code, at: at}
+	if !d.instruction() || d.at > len(code) {
+		return instruction{}, false
+	}
+	for i := range d.ops {
+		d.ops[i].at -= at
+	}
+	return instruction{d.at - at, d.ops}, true
+}
+
+func (d *decoder) byte() (byte, bool) {
+	if d.at >= len(d.code) {
+		return 0, false
+	}
+	b := d.code[d.at]
+	d.at++
+	return b, true
+}
+
+// skip passes n bytes of an operand that can't be an offset
+func (d *decoder) skip(n int) bool {
+	d.at += n
+	return true
+}
+
+// imm passes an operand of the operand size, noting it as kind if it's 16 bits
+func (d *decoder) imm(kind Kind) bool {
+	if d.wide {
+		return d.skip(4)
+	}
+	d.ops = append(d.ops, operand{d.at, kind})
+	return d.skip(2)
+}
+
+// modrm passes a ModR/M byte and what follows it, returning its reg field
+func (d *decoder) modrm() (byte, bool) {
+	b, ok := d.byte()
+	if !ok {
+		return 0, false
+	}
+	mod, reg, rm := b>>6, b>>3&7, b&7
+	if d.addr32 {
+		if mod != 3 && rm == 4 {
+			sib, ok := d.byte()
+			if !ok {
+				return 0, false
+			}
+			if mod == 0 && sib&7 == 5 {
+				d.skip(4)
+			}
+		}
+		switch {
+		case mod == 0 && rm == 5, mod == 2:
+			d.skip(4)
+		case mod == 1:
+			d.skip(1)
+		}
+		return reg, true
+	}
+	switch {
+	case mod == 0 && rm == 6, mod == 2:
+		d.ops = append(d.ops, operand{d.at, KindAddress})
+		d.skip(2)
+	case mod == 1:
+		d.skip(1)
+	}
+	return reg, true
+}
+
+// instruction decodes an instruction's prefixes, then the rest of it
+func (d *decoder) instruction() bool {
+	op, ok := d.byte()
+	for ok {
+		switch op {
+		case 0x26, 0x2E, 0x36, 0x3E, 0x64, 0x65, 0xF0, 0xF2, 0xF3:
+		case 0x66:
+			d.wide = true
+		case 0x67:
+			d.addr32 = true
+		default:
+			return d.operands(op)
+		}
+		op, ok = d.byte()
+	}
+	return false
+}
+
+// operands decodes the rest of an instruction after its prefixes and first opcode byte op
+func (d *decoder) operands(op byte) bool {
+	switch {
+	case op < 0x40 && op&7 < 4:
+		// add, or, adc, sbb, and, sub, xor, cmp with a ModR/M byte
+		_, ok := d.modrm()
+		return ok
+	case op < 0x40 && op&7 == 4:
+		return d.skip(1)
+	case op < 0x40 && op&7 == 5:
+		return d.imm(KindConstant)
+	case op == 0x0F:
+		return d.extended()
+	case op < 0x62, op >= 0x90 && op <= 0x99, op >= 0x9B && op <= 0x9F,
+		op >= 0x6C && op <= 0x6F, op >= 0xA4 && op <= 0xA7, op >= 0xAA && op <= 0xAF,
+		op == 0xC3, op == 0xC9, op == 0xCB, op == 0xCC, op == 0xCE, op == 0xCF,
+		op >= 0xD6 && op <= 0xD7, op >= 0xEC && op <= 0xEF, op == 0xF4, op == 0xF5, op >= 0xF8 && op <= 0xFD:
+		// No operands (including push, pop, inc, dec and xchg of registers)
+		return true
+	case op == 0x62, op == 0x63, op >= 0x84 && op <= 0x8F, op == 0xC4, op == 0xC5,
+		op >= 0xD0 && op <= 0xD3, op >= 0xD8 && op <= 0xDF, op == 0xFE, op == 0xFF:
+		_, ok := d.modrm()
+		return ok
+	case op == 0x68, op >= 0xB8 && op <= 0xBF:
+		// push imm, mov reg, imm
+		return d.imm(KindImmediate)
+	case op == 0x69, op == 0x81:
+		_, ok := d.modrm()
+		return ok && d.imm(KindConstant)
+	case op == 0x6B, op == 0x80, op == 0x82, op == 0x83, op == 0xC0, op == 0xC1, op == 0xC6:
+		_, ok := d.modrm()
+		return ok && d.skip(1)
+	case op == 0xC7:
+		// mov r/m, imm
+		reg, ok := d.modrm()
+		return ok && reg == 0 && d.imm(KindImmediate)
+	case op == 0xF6, op == 0xF7:
+		reg, ok := d.modrm()
+		if !ok || reg > 1 {
+			return ok
+		}
+		// test r/m, imm
+		if op == 0xF6 {
+			return d.skip(1)
+		}
+		return d.imm(KindConstant)
+	case op == 0x6A, op >= 0x70 && op <= 0x7F, op == 0xA8, op >= 0xB0 && op <= 0xB7, op == 0xCD,
+		op == 0xD4, op == 0xD5, op >= 0xE0 && op <= 0xE7, op == 0xEB:
+		return d.skip(1)
+	case op == 0xA0, op == 0xA1, op == 0xA2, op == 0xA3:
+		// mov between the accumulator and memory
+		if d.addr32 {
+			return d.skip(4)
+		}
+		d.ops = append(d.ops, operand{d.at, KindAddress})
+		return d.skip(2)
+	case op == 0xA9:
+		return d.imm(KindConstant)
+	case op == 0xC2, op == 0xCA:
+		return d.skip(2)
+	case op == 0xC8:
+		return d.skip(3)
+	case op == 0xE8, op == 0xE9:
+		if d.wide {
+			return d.skip(4)
+		}
+		return d.skip(2)
+	case op == 0x9A, op == 0xEA:
+		if d.wide {
+			return d.skip(6)
+		}
+		return d.skip(4)
+	}
+	return false
+}
+
+// extended decodes the rest of an instruction that starts with 0F
+func (d *decoder) extended() bool {
+	op, ok := d.byte()
+	if !ok {
+		return false
+	}
+	switch {
+	case op <= 0x03, op >= 0x20 && op <= 0x23, op >= 0x90 && op <= 0x9F,
+		op == 0xA3, op == 0xA5, op == 0xAB, op == 0xAD, op == 0xAF, op >= 0xB0 && op <= 0xB7,
+		op >= 0xBB && op <= 0xBF:
+		_, ok := d.modrm()
+		return ok
+	case op == 0xA4, op == 0xAC, op == 0xBA:
+		_, ok := d.modrm()
+		return ok && d.skip(1)
+	case op == 0x06, op == 0x08, op == 0x09, op == 0x0B, op == 0xA0, op == 0xA1, op == 0xA2,
+		op == 0xA8, op == 0xA9, op >= 0xC8 && op <= 0xCF:
+		return true
+	case op >= 0x80 && op <= 0x8F:
+		// Near conditional jumps
+		if d.wide {
+			return d.skip(4)
+		}
+		return d.skip(2)
+	}
+	return false
+}
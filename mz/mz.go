@@ -0,0 +1,160 @@
+// Package mz reads DOS MZ executables: the header, the relocation table, and
+// where the load image and its segments are in the file.
+//
+// The load image starts after the header; segment values in the header and
+// relocation table are paragraphs (16 bytes) from the start of the load image,
+// before DOS adds the load address.
+package mz
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// HeaderSize is the size of the fixed part of the MZ header.
+const HeaderSize = 0x1C
+
+// Header is the fixed part of an MZ header.
+type Header struct {
+	LastPageBytes    uint16 // bytes used in the last 512-byte page, 0 if it's full
+	Pages            uint16 // 512-byte pages in the file, including the header
+	Relocations      uint16 // entries in the relocation table
+	HeaderParagraphs uint16 // size of the header in paragraphs
+	MinAlloc         uint16 // extra paragraphs needed
+	MaxAlloc         uint16 // extra paragraphs wanted
+	SS               uint16 // initial stack segment
+	SP               uint16 // initial stack pointer
+	Checksum         uint16
+	IP               uint16 // entry point offset
+	CS               uint16 // entry point segment
+	RelocOffset      uint16 // file offset of the relocation table
+	Overlay          uint16
+}
+
+// Relocation is the address of a segment word that DOS fixes up with the load address.
+type Relocation struct {
+	Offset  uint16
+	Segment uint16
+}
+
+// File is a parsed MZ executable.
+type File struct {
+	Header      Header
+	Relocations []Relocation
+
+	// ImageStart and ImageEnd are the file offsets of the load image.
+	ImageStart int
+	ImageEnd   int
+
+	data     []byte
+	relocSet map[int]bool
+	segs     []uint16
+}
+
+// Parse reads the MZ header and relocation table of data.
+func Parse(data []byte) (*File, error) {
+	if len(data) < HeaderSize || data[0] != 'M' || data[1] != 'Z' {
+		return nil, errors.New("not an MZ executable")
+	}
+
+	var h Header
+	fields := []*uint16{
+		&h.LastPageBytes, &h.Pages, &h.Relocations, &h.HeaderParagraphs, &h.MinAlloc, &h.MaxAlloc,
+		&h.SS, &h.SP, &h.Checksum, &h.IP, &h.CS, &h.RelocOffset, &h.Overlay,
+	}
+	for i, f := range fields {
+		*f = binary.LittleEndian.Uint16(data[2+2*i:])
+	}
+
+	f := &File{Header: h, data: data, relocSet: map[int]bool{}}
+	f.ImageStart = int(h.HeaderParagraphs) * 16
+	f.ImageEnd = int(h.Pages) * 512
+	if h.LastPageBytes != 0 {
+		f.ImageEnd -= 512 - int(h.LastPageBytes)
+	}
+	if f.ImageStart < HeaderSize || f.ImageStart > f.ImageEnd || f.ImageEnd > len(data) {
+		return nil, fmt.Errorf("load image %X-%X doesn't fit in the file (%X bytes)", f.ImageStart, f.ImageEnd, len(data))
+	}
+
+	tableEnd := int(h.RelocOffset) + 4*int(h.Relocations)
+	if h.Relocations > 0 && (int(h.RelocOffset) < HeaderSize || tableEnd > f.ImageStart) {
+		return nil, fmt.Errorf("relocation table %X-%X isn't within the header", h.RelocOffset, tableEnd)
+	}
+	for i := 0; i < int(h.Relocations); i++ {
+		at := int(h.RelocOffset) + 4*i
+		r := Relocation{Offset: binary.LittleEndian.Uint16(data[at:]), Segment: binary.LittleEndian.Uint16(data[at+2:])}
+		f.Relocations = append(f.Relocations, r)
+		f.relocSet[f.FileOffset(r.Segment, r.Offset)] = true
+	}
+	f.segs = f.knownSegments()
+	return f, nil
+}
+
+// Encode returns the fixed part of the header as stored in the file.
+func (h Header) Encode() []byte {
+	fields := []uint16{
+		h.LastPageBytes, h.Pages, h.Relocations, h.HeaderParagraphs, h.MinAlloc, h.MaxAlloc,
+		h.SS, h.SP, h.Checksum, h.IP, h.CS, h.RelocOffset, h.Overlay,
+	}
+	out := []byte{'M', 'Z'}
+	for _, v := range fields {
+		out = binary.LittleEndian.AppendUint16(out, v)
+	}
+	return out
+}
+
+// FileOffset returns the file offset of seg:off in the load image.
+func (f *File) FileOffset(seg, off uint16) int {
+	return f.ImageStart + int(seg)*16 + int(off)
+}
+
+// SegOff returns the offset of a file offset within segment seg, if the
+// offset is within the load image and 64K of the segment's start.
+func (f *File) SegOff(seg uint16, fileOffset int) (uint16, bool) {
+	off := fileOffset - f.FileOffset(seg, 0)
+	if fileOffset < f.ImageStart || fileOffset >= f.ImageEnd || off < 0 || off > 0xFFFF {
+		return 0, false
+	}
+	return uint16(off), true
+}
+
+// IsRelocated reports whether DOS fixes up the word at the given file offset
+// with the load address, i.e. it holds a segment.
+func (f *File) IsRelocated(fileOffset int) bool {
+	return f.relocSet[fileOffset]
+}
+
+// How the data segment was found
+const (
+	// FromStartup means the startup code loads it with mov dx, seg, as Borland's does.
+	FromStartup = "startup code"
+	// FromStack means it's the initial stack segment, as in small and medium model programs.
+	FromStack = "stack segment"
+)
+
+// startupScanLength is how far from the entry point DataSegment looks for the data segment load.
+const startupScanLength = 32
+
+// DataSegment finds the program's data segment (DGROUP), returning it and how it was found.
+//
+// Borland's startup code begins with mov dx, DGROUP (BA lo hi) to set up DS,
+// where the immediate is a relocated segment. Failing that, the stack
+// segment is used, which small and medium model programs keep in DGROUP.
+// It fails if the segment found doesn't start within the load image.
+func (f *File) DataSegment() (uint16, string, bool) {
+	entry := f.FileOffset(f.Header.CS, f.Header.IP)
+	for at := entry; at < entry+startupScanLength && at+3 <= f.ImageEnd; at++ {
+		if f.data[at] == 0xBA && f.IsRelocated(at+1) {
+			seg := binary.LittleEndian.Uint16(f.data[at+1:])
+			if f.FileOffset(seg, 0) < f.ImageEnd {
+				return seg, FromStartup, true
+			}
+		}
+	}
+
+	if f.FileOffset(f.Header.SS, 0) < f.ImageEnd {
+		return f.Header.SS, FromStack, true
+	}
+	return 0, "", false
+}
@@ -0,0 +1,110 @@
+package mz
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// buildExe returns an MZ executable with the given load image and
+// relocations; the entry point is 0000:0000.
+func buildExe(image []byte, ss uint16, relocs ...Relocation) []byte {
+	headerSize := (HeaderSize + 4*len(relocs) + 15) / 16 * 16
+	size := headerSize + len(image)
+	h := Header{
+		LastPageBytes:    uint16(size % 512),
+		Pages:            uint16((size + 511) / 512),
+		Relocations:      uint16(len(relocs)),
+		HeaderParagraphs: uint16(headerSize / 16),
+		SS:               ss,
+		RelocOffset:      HeaderSize,
+	}
+	out := h.Encode()
+	for _, r := range relocs {
+		out = binary.LittleEndian.AppendUint16(out, r.Offset)
+		out = binary.LittleEndian.AppendUint16(out, r.Segment)
+	}
+	out = append(out, make([]byte, headerSize-len(out))...)
+	return append(out, image...)
+}
+
+// startupReloc is the relocation of mov dx, seg at the entry point
+var startupReloc = Relocation{Offset: 1, Segment: 0}
+
+func TestParse(t *testing.T) {
+	image := make([]byte, 0x40)
+	data := buildExe(image, 3, startupReloc)
+	data = append(data, "overlay"...)
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if f.ImageStart != 32 || f.ImageEnd != 32+0x40 {
+		t.Errorf("Expected image 20-60, got %X-%X", f.ImageStart, f.ImageEnd)
+	}
+	if f.Header.SS != 3 || len(f.Relocations) != 1 || f.Relocations[0] != (Relocation{1, 0}) {
+		t.Errorf("Unexpected header %+v, relocations %+v", f.Header, f.Relocations)
+	}
+	if !f.IsRelocated(33) || f.IsRelocated(32) {
+		t.Error("Expected only the word at file offset 33 to be relocated")
+	}
+	if f.FileOffset(2, 5) != 32+0x25 {
+		t.Errorf("Expected 0002:0005 at %X, got %X", 32+0x25, f.FileOffset(2, 5))
+	}
+	if off, ok := f.SegOff(2, 32+0x25); !ok || off != 5 {
+		t.Errorf("Expected offset 5 in segment 2, got %v %v", off, ok)
+	}
+	if _, ok := f.SegOff(2, 32+0x40); ok {
+		t.Error("Expected no offset past the load image")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"Not MZ", []byte("This is not an executable at all.")},
+		{"Too short", []byte("MZ")},
+		{"Image past end of file", buildExe(make([]byte, 0x40), 0, startupReloc)[:0x40]},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse(tc.data); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestDataSegment(t *testing.T) {
+	// mov dx, 0002 with the immediate relocated, as in Borland's startup code
+	image := make([]byte, 0x40)
+	copy(image, []byte{0xBA, 0x02, 0x00})
+	f, err := Parse(buildExe(image, 3, startupReloc))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if seg, how, ok := f.DataSegment(); !ok || seg != 2 || how != FromStartup {
+		t.Errorf("Expected segment 2 from startup code, got %v %v %v", seg, how, ok)
+	}
+
+	// Without it, the stack segment is used
+	f, err = Parse(buildExe(make([]byte, 0x40), 3, startupReloc))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if seg, how, ok := f.DataSegment(); !ok || seg != 3 || how != FromStack {
+		t.Errorf("Expected segment 3 from stack segment, got %v %v %v", seg, how, ok)
+	}
+
+	// A stack segment past the load image isn't data
+	f, err = Parse(buildExe(make([]byte, 0x40), 0x10, startupReloc))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, _, ok := f.DataSegment(); ok {
+		t.Error("Expected no data segment")
+	}
+}
@@ -0,0 +1,173 @@
+package mz
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Kind is what holds the offset of a Reference
+type Kind string
+
+const (
+	// KindImmediate is the immediate of mov reg, imm16, push imm16, or mov r/m16, imm16.
+	KindImmediate Kind = "immediate"
+	// KindFar is the offset word of a far pointer in the data segment, whose segment word is relocated.
+	KindFar Kind = "far pointer"
+
+	// The rest hold the offset, but can't be told apart from something that only looks like it.
+
+	// KindConstant is the immediate of an instruction that computes with it, e.g. add or cmp.
+	KindConstant Kind = "constant"
+	// KindAddress is the address of a memory operand, which reads or writes at the offset.
+	KindAddress Kind = "address"
+	// KindUndecoded is a word in code that couldn't be decoded.
+	KindUndecoded Kind = "undecoded code"
+	// KindData is a word in the data segment, which may be a near pointer.
+	KindData Kind = "data"
+)
+
+// Reference is a place in the program that holds the offset of something in the data segment.
+type Reference struct {
+	At   int    // file offset of the offset word
+	Seg  uint16 // At as seg:off, see Locate
+	Off  uint16
+	Kind Kind
+}
+
+func (r Reference) String() string {
+	return fmt.Sprintf("%04X:%04X", r.Seg, r.Off)
+}
+
+// Confirmed reports whether r is known to be a reference that can be pointed elsewhere
+func (r Reference) Confirmed() bool {
+	return r.Kind == KindImmediate || r.Kind == KindFar
+}
+
+// References finds what may refer to offsets in data segment ds, by target offset.
+//
+// The code, which is taken to be the load image before ds, is decoded from
+// the start of the image, the entry point and each known segment. There,
+// immediates of mov reg, imm16, push imm16 and mov r/m16, imm16 are
+// confirmed references. In the data segment, far pointers whose segment word
+// is relocated and holds ds are too. Other immediates and addresses in the
+// code, words of code that couldn't be decoded, and every other word in the
+// data segment may hold an offset as well, and are returned for what they
+// may be, but not confirmed.
+func (f *File) References(ds uint16) map[uint16][]Reference {
+	refs := map[uint16][]Reference{}
+	seen := map[operand]bool{}
+	add := func(at int, kind Kind) {
+		if seen[operand{at, kind}] || f.IsRelocated(at) {
+			// Found already, or a segment rather than an offset
+			return
+		}
+		seen[operand{at, kind}] = true
+		target := binary.LittleEndian.Uint16(f.data[at:])
+		seg, off := f.Locate(at)
+		refs[target] = append(refs[target], Reference{At: at, Seg: seg, Off: off, Kind: kind})
+	}
+
+	dataStart := f.FileOffset(ds, 0)
+	codeEnd := min(dataStart, f.ImageEnd)
+	for _, r := range f.Relocations {
+		at := f.FileOffset(r.Segment, r.Offset)
+		if at-2 >= codeEnd && at+2 <= f.ImageEnd && binary.LittleEndian.Uint16(f.data[at:]) == ds {
+			add(at-2, KindFar)
+		}
+	}
+
+	// Where decodings out of step disagree, the same word is found as more than one kind
+	for _, at := range f.instructions(codeEnd) {
+		in, ok := decode(f.data[:codeEnd], at)
+		if !ok {
+			if at+2 <= codeEnd {
+				add(at, KindUndecoded)
+			}
+			continue
+		}
+		for _, o := range in.operands {
+			add(at+o.at, o.kind)
+		}
+	}
+	for at := max(dataStart, f.ImageStart); at+2 <= f.ImageEnd; at++ {
+		if !seen[operand{at, KindFar}] {
+			add(at, KindData)
+		}
+	}
+
+	for _, list := range refs {
+		sort.Slice(list, func(i, j int) bool { return list[i].At < list[j].At })
+	}
+	return refs
+}
+
+// instructions returns where decoding the code before codeEnd was tried: where
+// each instruction starts, and each byte that couldn't be decoded. The code is
+// decoded one instruction after another from the start of the image, then from
+// the entry point and the start of each known segment until it falls in step
+// with what was decoded already.
+func (f *File) instructions(codeEnd int) []int {
+	code := f.data[:codeEnd]
+	starts := map[int]bool{}
+	sweep := func(at int) {
+		for at >= f.ImageStart && at < codeEnd && !starts[at] {
+			starts[at] = true
+			in, ok := decode(code, at)
+			if !ok {
+				at++
+				continue
+			}
+			at += in.length
+		}
+	}
+	sweep(f.ImageStart)
+	sweep(f.FileOffset(f.Header.CS, f.Header.IP))
+	for _, s := range f.segs {
+		sweep(f.FileOffset(s, 0))
+	}
+
+	list := make([]int, 0, len(starts))
+	for at := range starts {
+		list = append(list, at)
+	}
+	sort.Ints(list)
+	return list
+}
+
+// knownSegments returns the segments the program is known to use, in order:
+// the entry and stack segments, and those named by relocations.
+func (f *File) knownSegments() []uint16 {
+	set := map[uint16]bool{0: true, f.Header.CS: true, f.Header.SS: true}
+	for _, r := range f.Relocations {
+		set[r.Segment] = true
+		if at := f.FileOffset(r.Segment, r.Offset); at+2 <= f.ImageEnd {
+			set[binary.LittleEndian.Uint16(f.data[at:])] = true
+		}
+	}
+
+	var segs []uint16
+	for s := range set {
+		segs = append(segs, s)
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	return segs
+}
+
+// Locate returns a file offset in the load image as seg:off, using the
+// last known segment that starts at or before it.
+func (f *File) Locate(fileOffset int) (uint16, uint16) {
+	seg := uint16(0)
+	for _, s := range f.segs {
+		if f.FileOffset(s, 0) <= fileOffset {
+			seg = s
+		}
+	}
+	off := fileOffset - f.FileOffset(seg, 0)
+	if off > 0xFFFF {
+		// No known segment is close enough; use the nearest paragraph
+		seg = uint16((fileOffset - f.ImageStart) / 16)
+		off = (fileOffset - f.ImageStart) % 16
+	}
+	return seg, uint16(off)
+}
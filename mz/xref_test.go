@@ -0,0 +1,113 @@
+package mz
+
+import (
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	// Segment 0 is code, segment 2 (file offset 0x40) is the data segment
+	image := make([]byte, 0x60)
+	copy(image, []byte{
+		0xBA, 0x02, 0x00, // mov dx, 0002 (relocated)
+		0xB8, 0x04, 0x00, // mov ax, 0004
+		0x68, 0x10, 0x00, // push 0010
+		0xC7, 0x46, 0xFE, 0x04, 0x00, // mov word [bp-2], 0004
+		0x3D, 0x10, 0x00, // cmp ax, 0010
+		0xA0, 0x14, 0x00, // mov al, [0014]
+		0xE8, 0x04, 0x00, // call +0004, not an offset
+		0x8B, 0x87, 0x04, 0x00, // mov ax, [bx+0004]
+	})
+	// A far pointer to 0002:0010 in the data segment, and a word that may be a near pointer to 0002:0014
+	copy(image[0x30:], []byte{0x10, 0x00, 0x02, 0x00, 0x14, 0x00})
+
+	// The far pointer's segment word at 0002:0012 is relocated too
+	f, err := Parse(buildExe(image, 2, startupReloc, Relocation{Offset: 0x12, Segment: 2}))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	refs := f.References(2)
+	at := f.ImageStart
+
+	expected := map[uint16][]Reference{
+		0x0004: {
+			{At: at + 4, Seg: 0, Off: 4, Kind: KindImmediate},
+			{At: at + 12, Seg: 0, Off: 12, Kind: KindImmediate},
+			{At: at + 25, Seg: 0, Off: 25, Kind: KindAddress},
+		},
+		0x0010: {
+			{At: at + 7, Seg: 0, Off: 7, Kind: KindImmediate},
+			{At: at + 15, Seg: 0, Off: 15, Kind: KindConstant},
+			{At: at + 0x30, Seg: 2, Off: 0x10, Kind: KindFar},
+		},
+		0x0014: {
+			{At: at + 18, Seg: 0, Off: 18, Kind: KindAddress},
+			{At: at + 0x34, Seg: 2, Off: 0x14, Kind: KindData},
+		},
+	}
+	for target, want := range expected {
+		got := refs[target]
+		if len(got) != len(want) {
+			t.Errorf("Target %04X: expected %v, got %+v", target, want, got)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Target %04X reference %d: expected %+v, got %+v", target, i, want[i], got[i])
+			}
+		}
+	}
+
+	// The relocated segment word of mov dx isn't an offset
+	for _, r := range refs[0x0002] {
+		if r.At == at+1 {
+			t.Errorf("Unexpected reference %+v from a relocated word", r)
+		}
+	}
+
+	if s := refs[0x0010][2].String(); s != "0002:0010" {
+		t.Errorf("Expected 0002:0010, got %v", s)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     []byte
+		length   int
+		operands []operand
+	}{
+		{"mov reg", []byte{0xB8, 0x34, 0x12}, 3, []operand{{1, KindImmediate}}},
+		{"push", []byte{0x68, 0x34, 0x12}, 3, []operand{{1, KindImmediate}}},
+		{"mov mem", []byte{0xC7, 0x06, 0x00, 0x01, 0x34, 0x12}, 6, []operand{{2, KindAddress}, {4, KindImmediate}}},
+		{"mov bp", []byte{0xC7, 0x46, 0xFE, 0x34, 0x12}, 5, []operand{{3, KindImmediate}}},
+		{"add", []byte{0x81, 0xC6, 0x34, 0x12}, 4, []operand{{2, KindConstant}}},
+		{"prefixed", []byte{0x26, 0x8B, 0x1E, 0x34, 0x12}, 5, []operand{{3, KindAddress}}},
+		{"32-bit", []byte{0x66, 0xB8, 0x34, 0x12, 0x00, 0x00}, 6, nil},
+		{"32-bit address", []byte{0x67, 0x8B, 0x04, 0x24}, 4, nil},
+		{"call", []byte{0xE8, 0x34, 0x12}, 3, nil},
+		{"far call", []byte{0x9A, 0x34, 0x12, 0x00, 0x00}, 5, nil},
+		{"jump", []byte{0x0F, 0x84, 0x34, 0x12}, 4, nil},
+		{"test", []byte{0xF7, 0xC1, 0x34, 0x12}, 4, []operand{{2, KindConstant}}},
+		{"not", []byte{0xF7, 0xD1}, 2, nil},
+		{"short", []byte{0xC3}, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, ok := decode(tt.code, 0)
+			if !ok || in.length != tt.length || len(in.operands) != len(tt.operands) {
+				t.Fatalf("Expected %v bytes with operands %v, got %v %+v", tt.length, tt.operands, ok, in)
+			}
+			for i, o := range tt.operands {
+				if in.operands[i] != o {
+					t.Errorf("Operand %v: expected %+v, got %+v", i, o, in.operands[i])
+				}
+			}
+		})
+	}
+
+	for _, code := range [][]byte{{0xB8, 0x34}, {0xF1}, {0xC7, 0xC8, 0x34, 0x12}, {0x66}} {
+		if in, ok := decode(code, 0); ok {
+			t.Errorf("Expected [% X] not to decode, got %+v", code, in)
+		}
+	}
+}
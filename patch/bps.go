@@ -0,0 +1,230 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// BPS format: "BPS1", the source, target and metadata sizes as variable-length
+// numbers, the metadata, then actions that build the target in order, and
+// finally the CRC32s of the source, target and patch, little-endian.
+const bpsHeader = "BPS1"
+
+// BPS actions, the low 2 bits of each action's number; the rest is its length - 1
+const (
+	bpsSourceRead = iota // copy from source at the same offset as in the target
+	bpsTargetRead        // literal bytes follow
+	bpsSourceCopy        // copy from source at a relative offset
+	bpsTargetCopy        // copy from what's been written of the target at a relative offset
+)
+
+// bpsMinMatch is the shortest match in source worth a copy action
+const bpsMinMatch = 6
+
+// MakeBPS returns a BPS patch that turns source into target.
+//
+// Unlike IPS, BPS can copy from anywhere in the source, so text that moved
+// when a string before it changed length costs a few bytes rather than a copy.
+func MakeBPS(source, target []byte) []byte {
+	out := []byte(bpsHeader)
+	out = appendVarint(out, uint64(len(source)))
+	out = appendVarint(out, uint64(len(target)))
+	out = appendVarint(out, 0) // no metadata
+
+	// Index where each short sequence occurs in the source, to find matches
+	index := map[uint32][]int{}
+	for i := 0; i+bpsMinMatch <= len(source); i++ {
+		k := binary.LittleEndian.Uint32(source[i:])
+		if len(index[k]) < 32 {
+			index[k] = append(index[k], i)
+		}
+	}
+
+	sourceAt := 0 // where the last source copy left off, which copies are relative to
+	literalStart := 0
+	flushLiteral := func(end int) {
+		if end > literalStart {
+			out = appendVarint(out, uint64(end-literalStart-1)<<2|bpsTargetRead)
+			out = append(out, target[literalStart:end]...)
+		}
+	}
+
+	for at := 0; at < len(target); {
+		// Same place in the source is cheapest
+		same := 0
+		for at+same < len(target) && at+same < len(source) && source[at+same] == target[at+same] {
+			same++
+		}
+
+		best, bestLen := -1, 0
+		if at+bpsMinMatch <= len(target) {
+			for _, cand := range index[binary.LittleEndian.Uint32(target[at:])] {
+				n := 0
+				for cand+n < len(source) && at+n < len(target) && source[cand+n] == target[at+n] {
+					n++
+				}
+				if n > bestLen {
+					best, bestLen = cand, n
+				}
+			}
+		}
+
+		switch {
+		case same >= bpsMinMatch && same >= bestLen:
+			flushLiteral(at)
+			out = appendVarint(out, uint64(same-1)<<2|bpsSourceRead)
+			at += same
+			literalStart = at
+		case bestLen >= bpsMinMatch:
+			flushLiteral(at)
+			out = appendVarint(out, uint64(bestLen-1)<<2|bpsSourceCopy)
+			out = appendSignedVarint(out, best-sourceAt)
+			sourceAt = best + bestLen
+			at += bestLen
+			literalStart = at
+		default:
+			at++
+		}
+	}
+	flushLiteral(len(target))
+
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(source))
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(out))
+}
+
+// ApplyBPS applies a BPS patch to source, returning the target. It fails if
+// the patch is damaged, or source isn't the file it was made for.
+func ApplyBPS(source, patch []byte) ([]byte, error) {
+	if len(patch) < len(bpsHeader)+12 || !bytes.HasPrefix(patch, []byte(bpsHeader)) {
+		return nil, errors.New("not a BPS patch")
+	}
+	footer := patch[len(patch)-12:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(footer[8:]) {
+		return nil, errors.New("BPS patch is damaged (checksum mismatch)")
+	}
+	if crc32.ChecksumIEEE(source) != binary.LittleEndian.Uint32(footer[0:]) {
+		return nil, errors.New("BPS patch is for a different source file (checksum mismatch)")
+	}
+
+	r := &varintReader{data: patch[:len(patch)-12], at: len(bpsHeader)}
+	sourceSize := r.next()
+	targetSize := r.next()
+	r.at += int(r.next()) // metadata
+	if r.err != nil {
+		return nil, r.err
+	}
+	if sourceSize != uint64(len(source)) {
+		return nil, fmt.Errorf("BPS patch is for a %v-byte source, not %v bytes", sourceSize, len(source))
+	}
+
+	target := make([]byte, 0, targetSize)
+	sourceAt, targetAt := 0, 0
+	for r.at < len(r.data) && r.err == nil {
+		action := r.next()
+		n := int(action>>2) + 1
+		switch action & 3 {
+		case bpsSourceRead:
+			at := len(target)
+			if at+n > len(source) {
+				return nil, errors.New("BPS source read past the end of the source")
+			}
+			target = append(target, source[at:at+n]...)
+		case bpsTargetRead:
+			if r.at+n > len(r.data) {
+				return nil, errors.New("BPS target read past the end of the patch")
+			}
+			target = append(target, r.data[r.at:r.at+n]...)
+			r.at += n
+		case bpsSourceCopy:
+			sourceAt += r.nextSigned()
+			if sourceAt < 0 || sourceAt+n > len(source) {
+				return nil, errors.New("BPS source copy outside the source")
+			}
+			target = append(target, source[sourceAt:sourceAt+n]...)
+			sourceAt += n
+		case bpsTargetCopy:
+			targetAt += r.nextSigned()
+			if targetAt < 0 || targetAt >= len(target) {
+				return nil, errors.New("BPS target copy outside the target")
+			}
+			// Byte by byte, since the copy may overlap what it writes
+			for range n {
+				target = append(target, target[targetAt])
+				targetAt++
+			}
+		}
+		if uint64(len(target)) > targetSize {
+			return nil, errors.New("BPS patch writes past the target size")
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if uint64(len(target)) != targetSize || crc32.ChecksumIEEE(target) != binary.LittleEndian.Uint32(footer[4:]) {
+		return nil, errors.New("BPS patch produced the wrong target (checksum mismatch)")
+	}
+	return target, nil
+}
+
+// appendVarint appends v in BPS's variable-length encoding: 7 bits at a time,
+// low first, with the top bit set on the last byte, and each continuation
+// counting from 1 so every number has one encoding.
+func appendVarint(out []byte, v uint64) []byte {
+	for {
+		x := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 {
+			return append(out, 0x80|x)
+		}
+		out = append(out, x)
+		v--
+	}
+}
+
+// appendSignedVarint appends a relative offset: its magnitude, shifted, with the sign in the low bit
+func appendSignedVarint(out []byte, v int) []byte {
+	if v < 0 {
+		return appendVarint(out, uint64(-v)<<1|1)
+	}
+	return appendVarint(out, uint64(v)<<1)
+}
+
+// varintReader reads BPS numbers, remembering the first error
+type varintReader struct {
+	data []byte
+	at   int
+	err  error
+}
+
+func (r *varintReader) next() uint64 {
+	var v uint64
+	shift := uint64(1)
+	for {
+		if r.at >= len(r.data) {
+			if r.err == nil {
+				r.err = errors.New("BPS patch truncated")
+			}
+			return 0
+		}
+		x := r.data[r.at]
+		r.at++
+		v += uint64(x&0x7F) * shift
+		if x&0x80 != 0 {
+			return v
+		}
+		shift <<= 7
+		v += shift
+	}
+}
+
+func (r *varintReader) nextSigned() int {
+	v := r.next()
+	if v&1 != 0 {
+		return -int(v >> 1)
+	}
+	return int(v >> 1)
+}
@@ -0,0 +1,185 @@
+package patch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ManifestName is the manifest's file name in a bundle.
+const ManifestName = "manifest.json"
+
+// Manifest lists the files a bundle changes, and the patches that change them.
+type Manifest struct {
+	Files []FileEntry `json:"files"`
+}
+
+// FileEntry is a file changed by a bundle. Paths are relative, with forward slashes.
+type FileEntry struct {
+	Path         string `json:"path"`          // of the file in the game directory
+	SourceSHA256 string `json:"source_sha256"` // of the original file the patches apply to
+	SourceSize   int    `json:"source_size"`
+	TargetSHA256 string `json:"target_sha256"` // of the patched file
+	TargetSize   int    `json:"target_size"`
+	IPS          string `json:"ips,omitempty"` // patch files in the bundle
+	BPS          string `json:"bps"`
+}
+
+// Hash returns the SHA-256 of data in hex, as used in manifests.
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteBundle writes IPS and BPS patches for every file under sourceDir that
+// differs under targetDir, and their manifest, to outDir. Files too big for IPS
+// only get a BPS patch.
+func WriteBundle(sourceDir, targetDir, outDir string) (*Manifest, error) {
+	m := &Manifest{Files: []FileEntry{}}
+	err := filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		target, err := os.ReadFile(filepath.Join(targetDir, rel))
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(source, target) {
+			return nil
+		}
+
+		entry := FileEntry{
+			Path:         filepath.ToSlash(rel),
+			SourceSHA256: Hash(source),
+			SourceSize:   len(source),
+			TargetSHA256: Hash(target),
+			TargetSize:   len(target),
+			BPS:          filepath.ToSlash(rel) + ".bps",
+		}
+		if err := writeFile(outDir, entry.BPS, MakeBPS(source, target)); err != nil {
+			return err
+		}
+		if ips, err := MakeIPS(source, target); err == nil {
+			entry.IPS = filepath.ToSlash(rel) + ".ips"
+			if err := writeFile(outDir, entry.IPS, ips); err != nil {
+				return err
+			}
+		}
+		m.Files = append(m.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't make patches: %w", err)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(outDir, ManifestName, append(data, '\n')); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReadManifest reads the manifest of the bundle in dir. It fails if the
+// manifest names a patch file outside dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("couldn't parse manifest: %w", err)
+	}
+	for _, e := range m.Files {
+		for _, name := range []string{e.IPS, e.BPS} {
+			if name != "" && !filepath.IsLocal(filepath.FromSlash(name)) {
+				return nil, fmt.Errorf("manifest names a patch outside the bundle: '%v'", name)
+			}
+		}
+	}
+	return &m, nil
+}
+
+// RemoveBundle removes the bundle in dir, if there is one: its manifest and
+// the patch files it lists. Anything else in dir is left alone.
+func RemoveBundle(dir string) error {
+	m, err := ReadManifest(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, e := range m.Files {
+		for _, name := range []string{e.IPS, e.BPS} {
+			if name == "" {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, filepath.FromSlash(name))); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("couldn't remove %v: %w", name, err)
+			}
+		}
+	}
+	if err := os.Remove(filepath.Join(dir, ManifestName)); err != nil {
+		return fmt.Errorf("couldn't remove manifest: %w", err)
+	}
+	return nil
+}
+
+// Apply patches source, the original file, with the entry's patch from the bundle in dir.
+// It fails unless source and the result match the hashes in the manifest. BPS is used if
+// the bundle has it, as it checks itself; otherwise IPS.
+func (e FileEntry) Apply(dir string, source []byte) ([]byte, error) {
+	if len(source) != e.SourceSize || Hash(source) != e.SourceSHA256 {
+		return nil, fmt.Errorf("%v isn't the original file this patch is for", e.Path)
+	}
+
+	name, apply := e.BPS, ApplyBPS
+	if name == "" {
+		name, apply = e.IPS, ApplyIPS
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no patch for %v in the bundle", e.Path)
+	}
+	p, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read patch for %v: %w", e.Path, err)
+	}
+	target, err := apply(source, p)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't patch %v: %w", e.Path, err)
+	}
+	if len(target) != e.TargetSize || Hash(target) != e.TargetSHA256 {
+		return nil, fmt.Errorf("patching %v didn't give the expected file", e.Path)
+	}
+	return target, nil
+}
+
+// writeFile writes data to the slash-separated path rel under dir, creating directories as needed
+func writeFile(dir, rel string, data []byte) error {
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("couldn't create directory for '%v': %w", rel, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("couldn't write '%v': %w", rel, err)
+	}
+	return nil
+}
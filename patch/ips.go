@@ -0,0 +1,155 @@
+// Package patch makes and applies binary patches in the IPS and BPS formats
+// that fan translations are distributed as, and bundles them with a manifest
+// of the files they apply to.
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// IPS format: "PATCH", then records of a 3-byte offset, a 2-byte size and
+// that many bytes, or a size of 0, a 2-byte run length and the byte to repeat;
+// then "EOF", optionally followed by a 3-byte size to truncate the file to.
+// Numbers are big-endian.
+const (
+	ipsHeader  = "PATCH"
+	ipsFooter  = "EOF"
+	ipsMaxSize = 1 << 24 // offsets are 3 bytes
+	ipsMaxData = 0xFFFF
+
+	// ipsMinRun is the shortest run of a byte worth its own record
+	ipsMinRun = 8
+)
+
+// ipsEOF is the offset that would read as the footer, so no record can start there
+var ipsEOF = int(ipsFooter[0])<<16 | int(ipsFooter[1])<<8 | int(ipsFooter[2])
+
+// MakeIPS returns an IPS patch that turns source into target.
+// It fails if target is too big for IPS offsets.
+func MakeIPS(source, target []byte) ([]byte, error) {
+	if len(target) > ipsMaxSize {
+		return nil, fmt.Errorf("target is %v bytes, more than IPS can address", len(target))
+	}
+
+	out := []byte(ipsHeader)
+	for at := 0; at < len(target); {
+		if at < len(source) && source[at] == target[at] {
+			at++
+			continue
+		}
+
+		// Gather the changed bytes, up to where source and target agree again
+		begin := at
+		if begin == ipsEOF {
+			// A record can't start at the footer's offset, so start it one byte earlier
+			begin--
+		}
+		end := at
+		for end < len(target) && end-begin < ipsMaxData && !(end < len(source) && source[end] == target[end]) {
+			end++
+		}
+
+		if run := runLength(target[begin:end]); run >= ipsMinRun {
+			out = appendBE(out, begin, 3)
+			out = appendBE(out, 0, 2)
+			out = appendBE(out, run, 2)
+			out = append(out, target[begin])
+			at = begin + run
+			continue
+		}
+		out = appendBE(out, begin, 3)
+		out = appendBE(out, end-begin, 2)
+		out = append(out, target[begin:end]...)
+		at = end
+	}
+	out = append(out, ipsFooter...)
+	if len(target) < len(source) {
+		out = appendBE(out, len(target), 3)
+	}
+	return out, nil
+}
+
+// runLength returns how many times data's first byte repeats at its start
+func runLength(data []byte) int {
+	n := 0
+	for n < len(data) && data[n] == data[0] {
+		n++
+	}
+	return n
+}
+
+// ApplyIPS applies an IPS patch to source, returning the patched copy.
+func ApplyIPS(source, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, []byte(ipsHeader)) {
+		return nil, errors.New("not an IPS patch")
+	}
+	out := bytes.Clone(source)
+	at := len(ipsHeader)
+	for {
+		if at+3 > len(patch) {
+			return nil, errors.New("IPS patch ends without EOF")
+		}
+		if string(patch[at:at+3]) == ipsFooter {
+			at += 3
+			break
+		}
+		if at+5 > len(patch) {
+			return nil, errors.New("IPS record truncated")
+		}
+		offset := readBE(patch[at:], 3)
+		size := readBE(patch[at+3:], 2)
+		at += 5
+
+		var data []byte
+		if size == 0 {
+			if at+3 > len(patch) {
+				return nil, errors.New("IPS run record truncated")
+			}
+			data = bytes.Repeat(patch[at+2:at+3], readBE(patch[at:], 2))
+			at += 3
+		} else {
+			if at+size > len(patch) {
+				return nil, errors.New("IPS record truncated")
+			}
+			data = patch[at : at+size]
+			at += size
+		}
+
+		if end := offset + len(data); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], data)
+	}
+
+	switch len(patch) - at {
+	case 0:
+	case 3:
+		size := readBE(patch[at:], 3)
+		if size > len(out) {
+			return nil, fmt.Errorf("IPS truncation to %v bytes would grow the file", size)
+		}
+		out = out[:size]
+	default:
+		return nil, errors.New("unexpected data after IPS EOF")
+	}
+	return out, nil
+}
+
+// appendBE appends the n-byte big-endian form of v
+func appendBE(out []byte, v, n int) []byte {
+	for i := n - 1; i >= 0; i-- {
+		out = append(out, byte(v>>(8*i)))
+	}
+	return out
+}
+
+// readBE reads an n-byte big-endian number
+func readBE(data []byte, n int) int {
+	v := 0
+	for i := range n {
+		v = v<<8 | int(data[i])
+	}
+	return v
+}
@@ -0,0 +1,178 @@
+package patch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// patchCases are source and target pairs that patches must turn one into the other
+var patchCases = []struct {
+	name           string
+	source, target []byte
+}{
+	{"Identical", []byte("Hello, world!"), []byte("Hello, world!")},
+	{"Changed in place", []byte("Hello, world!\x00Goodbye\x00"), []byte("Ahoj, svete!!\x00Nashle!\x00")},
+	{"Grown", []byte("New game\x00Quit\x00Load game\x00"), []byte("Nova hra\x00Konec hry\x00Nahrat hru\x00")},
+	{"Shrunk", []byte("Continue the game\x00Quit\x00Options menu\x00"), []byte("Dal\x00Konec\x00Volby\x00")},
+	{"Run", append([]byte("abc"), make([]byte, 40)...), append([]byte("abc"), bytes.Repeat([]byte{'x'}, 40)...)},
+	{"Empty target", []byte("something"), []byte{}},
+	{"Text shifted", bytes.Repeat([]byte("0123456789abcdef"), 64), append([]byte("inserted"), bytes.Repeat([]byte("0123456789abcdef"), 64)...)},
+}
+
+func TestIPS(t *testing.T) {
+	for _, tc := range patchCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := MakeIPS(tc.source, tc.target)
+			if err != nil {
+				t.Fatalf("MakeIPS failed: %v", err)
+			}
+			got, err := ApplyIPS(tc.source, p)
+			if err != nil {
+				t.Fatalf("ApplyIPS failed: %v", err)
+			}
+			if !bytes.Equal(got, tc.target) {
+				t.Errorf("Expected %q, got %q", tc.target, got)
+			}
+		})
+	}
+}
+
+func TestIPSFooterOffset(t *testing.T) {
+	// A change at the offset that reads as "EOF" must not end the patch early
+	source := make([]byte, ipsEOF+16)
+	target := bytes.Clone(source)
+	target[ipsEOF] = 1
+	p, err := MakeIPS(source, target)
+	if err != nil {
+		t.Fatalf("MakeIPS failed: %v", err)
+	}
+	got, err := ApplyIPS(source, p)
+	if err != nil {
+		t.Fatalf("ApplyIPS failed: %v", err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Error("Expected the byte at the footer's offset to be patched")
+	}
+}
+
+func TestBPS(t *testing.T) {
+	for _, tc := range patchCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := MakeBPS(tc.source, tc.target)
+			got, err := ApplyBPS(tc.source, p)
+			if err != nil {
+				t.Fatalf("ApplyBPS failed: %v", err)
+			}
+			if !bytes.Equal(got, tc.target) {
+				t.Errorf("Expected %q, got %q", tc.target, got)
+			}
+		})
+	}
+}
+
+func TestBPSErrors(t *testing.T) {
+	source := []byte("Hello, world!\x00Goodbye\x00")
+	p := MakeBPS(source, []byte("Ahoj, svete!\x00Nashle\x00"))
+
+	if _, err := ApplyBPS([]byte("Something else entirely"), p); err == nil {
+		t.Error("Expected an error for the wrong source")
+	}
+	damaged := bytes.Clone(p)
+	damaged[len(bpsHeader)+4] ^= 0xFF
+	if _, err := ApplyBPS(source, damaged); err == nil {
+		t.Error("Expected an error for a damaged patch")
+	}
+}
+
+func TestBPSShiftedTextIsSmall(t *testing.T) {
+	// Inserting text before a long run of unchanged text shouldn't cost a copy of it
+	source := bytes.Repeat([]byte("Some text that the game shows.\x00"), 100)
+	target := append([]byte("Inserted\x00"), source...)
+	if p := MakeBPS(source, target); len(p) > 100 {
+		t.Errorf("Expected a small patch, got %v bytes", len(p))
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, data string) {
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("og/GAME.EXE", "MZ Hello\x00")
+	write("og/TEXTS.FIL", "unchanged")
+	write("built/GAME.EXE", "MZ Ahoj!\x00")
+	write("built/TEXTS.FIL", "unchanged")
+
+	m, err := WriteBundle(filepath.Join(dir, "og"), filepath.Join(dir, "built"), filepath.Join(dir, "patch"))
+	if err != nil {
+		t.Fatalf("WriteBundle failed: %v", err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Path != "GAME.EXE" || m.Files[0].SourceSHA256 != Hash([]byte("MZ Hello\x00")) {
+		t.Fatalf("Expected only GAME.EXE in the manifest, got %+v", m.Files)
+	}
+
+	read, err := ReadManifest(filepath.Join(dir, "patch"))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if len(read.Files) != 1 || read.Files[0] != m.Files[0] {
+		t.Errorf("Expected the manifest to read back the same, got %+v", read.Files)
+	}
+	for _, name := range []string{"GAME.EXE.ips", "GAME.EXE.bps"} {
+		if _, err := os.Stat(filepath.Join(dir, "patch", name)); err != nil {
+			t.Errorf("Expected %v to be written: %v", name, err)
+		}
+	}
+}
+
+func TestFileEntryApply(t *testing.T) {
+	dir := t.TempDir()
+	source := []byte("MZ Hello\x00")
+	target := []byte("MZ Ahoj!\x00")
+	ips, err := MakeIPS(source, target)
+	if err != nil {
+		t.Fatalf("MakeIPS failed: %v", err)
+	}
+	if err := writeFile(dir, "GAME.EXE.ips", ips); err != nil {
+		t.Fatal(err)
+	}
+	entry := FileEntry{Path: "GAME.EXE", SourceSHA256: Hash(source), SourceSize: len(source),
+		TargetSHA256: Hash(target), TargetSize: len(target), IPS: "GAME.EXE.ips"}
+
+	got, err := entry.Apply(dir, source)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if !bytes.Equal(got, target) {
+		t.Errorf("Expected %q, got %q", target, got)
+	}
+
+	// IPS can't tell it's applied to the wrong file; the manifest can
+	if _, err := entry.Apply(dir, []byte("MZ Howdy\x00")); err == nil {
+		t.Error("Expected an error for the wrong source file")
+	}
+}
+
+func TestReadManifestOutsideBundle(t *testing.T) {
+	for _, entry := range []string{
+		`{"path": "GAME.EXE", "bps": "../GAME.EXE.bps"}`,
+		`{"path": "GAME.EXE", "ips": "/etc/passwd", "bps": "GAME.EXE.bps"}`,
+	} {
+		dir := t.TempDir()
+		if err := writeFile(dir, ManifestName, []byte(`{"files": [`+entry+`]}`)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadManifest(dir); err == nil || !strings.Contains(err.Error(), "outside the bundle") {
+			t.Errorf("Expected %v to be refused, got %v", entry, err)
+		}
+	}
+}
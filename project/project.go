@@ -0,0 +1,172 @@
+// Package project reads qadam.json, the manifest of a translation project:
+// where the original game is, which of its files hold text and how they're
+// extracted, where the extracted and built files go, and the options to
+// extract and build with.
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestName is the file name of a project manifest.
+const ManifestName = "qadam.json"
+
+// Codecs say how a file's text is extracted and built.
+const (
+	// CodecFIL is for .FIL data files, extracted as their sections and records.
+	CodecFIL = "fil"
+	// CodecEXE is for executables, extracted as a patch file of their strings.
+	CodecEXE = "exe"
+)
+
+// File is a file of the game that holds text.
+type File struct {
+	// Name is the file's name in the game directory, e.g. TEXTS.FIL.
+	Name string `json:"name"`
+	// Codec is how its text is extracted and built, CodecFIL or CodecEXE.
+	Codec string `json:"codec"`
+	// Text is the name of the file its text is extracted to, e.g. texts.txt.
+	Text string `json:"text"`
+	// SizeField is set if the executable named by Project.SizeFieldsIn holds the
+	// file's size, which build then updates.
+	SizeField bool `json:"sizeField,omitempty"`
+}
+
+// DefaultSizeFieldsIn is the executable that holds the sizes of the data files,
+// unless a project says otherwise.
+const DefaultSizeFieldsIn = "GAME.EXE"
+
+// DefaultFiles are the files of the game that hold text, as extracted
+// without a project.
+var DefaultFiles = []File{
+	{Name: "TEXTS.FIL", Codec: CodecFIL, Text: "texts.txt", SizeField: true},
+	{Name: "RESOURCE.FIL", Codec: CodecFIL, Text: "resource.txt", SizeField: true},
+	{Name: "GAME.EXE", Codec: CodecEXE, Text: "game_exe.txt"},
+	{Name: "INSTALL.EXE", Codec: CodecEXE, Text: "install_exe.txt"},
+}
+
+// Options are the options of a project. Each is the same as the command line
+// flag of the same name, which can also turn it on.
+type Options struct {
+	AllStrings   bool `json:"allStrings,omitempty"`
+	KnownEdition bool `json:"knownEdition,omitempty"`
+	NoLint       bool `json:"noLint,omitempty"`
+	Lenient      bool `json:"lenient,omitempty"`
+	Relocate     bool `json:"relocate,omitempty"`
+	Pack         bool `json:"pack,omitempty"`
+	TrustRefs    bool `json:"trustRefs,omitempty"`
+}
+
+// Project is a translation project, as described by its manifest. Paths are
+// relative to the directory of the manifest; use Path to resolve them.
+type Project struct {
+	// Game is the directory of the original game.
+	Game string `json:"game"`
+	// Language is the language the game is translated to, e.g. "en".
+	Language string `json:"language,omitempty"`
+	// Extracted is where extract writes, and build reads; "extracted" if empty.
+	Extracted string `json:"extracted,omitempty"`
+	// Built is where build writes the translated game; "built" if empty.
+	Built string `json:"built,omitempty"`
+	// Patch, if set, is where build writes a patch bundle instead of the built game.
+	Patch string `json:"patch,omitempty"`
+	// Files are the files of the game that hold text; DefaultFiles if empty.
+	Files []File `json:"files,omitempty"`
+	// SizeFieldsIn is the executable that holds the sizes of the files marked
+	// SizeField; DefaultSizeFieldsIn if empty.
+	SizeFieldsIn string  `json:"sizeFieldsIn,omitempty"`
+	Options      Options `json:"options,omitempty"`
+
+	dir string // of the manifest
+}
+
+// Find returns the path of the manifest at path, which may be the manifest
+// itself or a directory holding one, and whether there is one.
+func Find(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	if info.IsDir() {
+		path = filepath.Join(path, ManifestName)
+		if _, err := os.Stat(path); err != nil {
+			return "", false
+		}
+		return path, true
+	}
+	return path, strings.EqualFold(filepath.Ext(path), ".json")
+}
+
+// Load reads the manifest at path, and checks it.
+func Load(path string) (*Project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read project: %w", err)
+	}
+	p := &Project{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("couldn't read project %v: %w", path, err)
+	}
+	p.dir = filepath.Dir(path)
+
+	if p.Extracted == "" {
+		p.Extracted = "extracted"
+	}
+	if p.Built == "" {
+		p.Built = "built"
+	}
+	if len(p.Files) == 0 {
+		p.Files = DefaultFiles
+	}
+	if p.SizeFieldsIn == "" {
+		p.SizeFieldsIn = DefaultSizeFieldsIn
+	}
+	if err := p.Check(); err != nil {
+		return nil, fmt.Errorf("project %v: %w", path, err)
+	}
+	return p, nil
+}
+
+// Check reports the first problem with the project's files.
+func (p *Project) Check() error {
+	names := map[string]bool{}
+	texts := map[string]bool{}
+	for _, f := range p.Files {
+		switch {
+		case f.Name == "" || f.Text == "":
+			return errors.New("every file needs a name and a text file")
+		case f.Codec != CodecFIL && f.Codec != CodecEXE:
+			return fmt.Errorf("%v has codec '%v'; expected '%v' or '%v'", f.Name, f.Codec, CodecFIL, CodecEXE)
+		case f.SizeField && f.Codec != CodecFIL:
+			return fmt.Errorf("%v: only the sizes of data files are kept in %v", f.Name, p.SizeFieldsIn)
+		case !filepath.IsLocal(f.Name) || !filepath.IsLocal(f.Text):
+			return fmt.Errorf("%v: file names must be within the game and extracted directories", f.Name)
+		case names[strings.ToUpper(f.Name)]:
+			return fmt.Errorf("%v is listed twice", f.Name)
+		case texts[strings.ToLower(f.Text)]:
+			return fmt.Errorf("%v is the text file of two files", f.Text)
+		}
+		names[strings.ToUpper(f.Name)] = true
+		texts[strings.ToLower(f.Text)] = true
+	}
+	if p.SizeFieldsIn != "" && !filepath.IsLocal(p.SizeFieldsIn) {
+		return fmt.Errorf("%v: the executable holding the size fields must be within the game directory", p.SizeFieldsIn)
+	}
+	return nil
+}
+
+// Path resolves path, from the manifest, against the manifest's directory.
+func (p *Project) Path(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(p.dir, path)
+}
@@ -0,0 +1,81 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeManifest writes a manifest holding json to a temporary directory and returns its path
+func writeManifest(t *testing.T, json string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ManifestName)
+	if err := os.WriteFile(path, []byte(json), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeManifest(t, `{"game": "../game", "language": "en", "options": {"relocate": true}}`)
+	p, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	dir := filepath.Dir(path)
+	if got := p.Path(p.Game); got != filepath.Join(dir, "..", "game") {
+		t.Errorf("Expected the game next to the project, got %v", got)
+	}
+	if got := p.Path(p.Extracted); got != filepath.Join(dir, "extracted") {
+		t.Errorf("Expected the default extracted directory, got %v", got)
+	}
+	if got := p.Path(p.Built); got != filepath.Join(dir, "built") {
+		t.Errorf("Expected the default built directory, got %v", got)
+	}
+	if p.Path(p.Patch) != "" {
+		t.Errorf("Expected no patch directory, got %v", p.Patch)
+	}
+	if len(p.Files) != len(DefaultFiles) || !p.Options.Relocate || p.Language != "en" || p.SizeFieldsIn != DefaultSizeFieldsIn {
+		t.Errorf("Expected the defaults and the options given, got %+v", p)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{"Unknown field", `{"game": "game", "langauge": "en"}`, "unknown field"},
+		{"Unknown codec", `{"files": [{"name": "INTRO.DAT", "codec": "dat", "text": "intro.txt"}]}`, "codec 'dat'"},
+		{"No text file", `{"files": [{"name": "TEXTS.FIL", "codec": "fil"}]}`, "needs a name and a text file"},
+		{"Listed twice", `{"files": [{"name": "TEXTS.FIL", "codec": "fil", "text": "a.txt"}, {"name": "texts.fil", "codec": "fil", "text": "b.txt"}]}`, "listed twice"},
+		{"Same text file", `{"files": [{"name": "TEXTS.FIL", "codec": "fil", "text": "a.txt"}, {"name": "GAME.EXE", "codec": "exe", "text": "a.txt"}]}`, "text file of two files"},
+		{"Outside the directory", `{"files": [{"name": "../GAME.EXE", "codec": "exe", "text": "game.txt"}]}`, "within the game"},
+		{"Size fields outside the directory", `{"sizeFieldsIn": "../GAME.EXE"}`, "size fields must be within the game"},
+		{"Size of an executable", `{"files": [{"name": "INSTALL.EXE", "codec": "exe", "text": "install.txt", "sizeField": true}]}`, "only the sizes of data files"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(writeManifest(t, tc.json))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	path := writeManifest(t, `{}`)
+	if got, ok := Find(filepath.Dir(path)); !ok || got != path {
+		t.Errorf("Expected the manifest in the directory, got %v, %v", got, ok)
+	}
+	if got, ok := Find(path); !ok || got != path {
+		t.Errorf("Expected the manifest itself, got %v, %v", got, ok)
+	}
+	if _, ok := Find(t.TempDir()); ok {
+		t.Error("Expected no manifest in an empty directory")
+	}
+}
@@ -0,0 +1,101 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Severity says whether a diagnostic stops the build
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	// SeverityNote is for information about what the build did, such as strings it moved
+	SeverityNote Severity = "note"
+)
+
+// Diagnostic is a problem found in an input file.
+// Line and Column count from 1; they are 0 when the problem isn't tied to a place in the file.
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Fix      string   `json:"fix,omitempty"`
+}
+
+// String formats the diagnostic like a compiler: file:line:column: severity: message [code]
+func (d Diagnostic) String() string {
+	s := d.File
+	if d.Line > 0 {
+		s += fmt.Sprintf(":%d", d.Line)
+		if d.Column > 0 {
+			s += fmt.Sprintf(":%d", d.Column)
+		}
+	}
+	s += fmt.Sprintf(": %s: %s [%s]", d.Severity, d.Message, d.Code)
+	if d.Fix != "" {
+		s += "\n\tfix: " + d.Fix
+	}
+	return s
+}
+
+// Diagnostics collects every problem found in a run, so they can all be reported at once
+type Diagnostics []Diagnostic
+
+// Add appends a diagnostic
+func (ds *Diagnostics) Add(d Diagnostic) {
+	*ds = append(*ds, d)
+}
+
+// Count returns the number of diagnostics with the given severity
+func (ds Diagnostics) Count(severity Severity) int {
+	n := 0
+	for _, d := range ds {
+		if d.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// HasErrors reports whether any diagnostic is an error
+func (ds Diagnostics) HasErrors() bool {
+	return ds.Count(SeverityError) > 0
+}
+
+// Print writes every diagnostic in compiler style, one per line
+func (ds Diagnostics) Print(w io.Writer) {
+	for _, d := range ds {
+		fmt.Fprintln(w, d)
+	}
+}
+
+// WriteJSON writes the diagnostics as a JSON array
+func (ds Diagnostics) WriteJSON(w io.Writer) error {
+	if ds == nil {
+		ds = Diagnostics{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ds)
+}
+
+// WriteJSONFile writes the diagnostics as a JSON array to the file at path, or to stdout if path is "-"
+func (ds Diagnostics) WriteJSONFile(path string) error {
+	if path == "-" {
+		return ds.WriteJSON(os.Stdout)
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("couldn't create '%v': %w", path, err)
+	}
+	defer out.Close()
+	return ds.WriteJSON(out)
+}
@@ -0,0 +1,84 @@
+package shared
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDiagnosticString(t *testing.T) {
+	testCases := []struct {
+		name     string
+		diag     Diagnostic
+		expected string
+	}{
+		{
+			"Full position with fix",
+			Diagnostic{File: "texts.txt", Line: 3, Column: 19, Severity: SeverityError, Code: "missing-charset", Message: "character '€' missing from charset", Fix: "use a character from the game's charset"},
+			"texts.txt:3:19: error: character '€' missing from charset [missing-charset]\n\tfix: use a character from the game's charset",
+		},
+		{
+			"Line only",
+			Diagnostic{File: "game_exe.txt", Line: 7, Severity: SeverityWarning, Code: "too-long", Message: "string too long"},
+			"game_exe.txt:7: warning: string too long [too-long]",
+		},
+		{
+			"No position",
+			Diagnostic{File: "texts.txt", Severity: SeverityError, Code: "no-sections", Message: "no sections found"},
+			"texts.txt: error: no sections found [no-sections]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.diag.String() != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, tc.diag.String())
+			}
+		})
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	var ds Diagnostics
+	if ds.HasErrors() {
+		t.Error("Expected no errors in empty list")
+	}
+
+	ds.Add(Diagnostic{File: "a.txt", Line: 1, Severity: SeverityWarning, Code: "w", Message: "warn"})
+	if ds.HasErrors() {
+		t.Error("Expected warnings not to count as errors")
+	}
+
+	ds.Add(Diagnostic{File: "a.txt", Line: 2, Column: 4, Severity: SeverityError, Code: "e", Message: "err"})
+	if !ds.HasErrors() || ds.Count(SeverityError) != 1 || ds.Count(SeverityWarning) != 1 {
+		t.Errorf("Unexpected counts in %+v", ds)
+	}
+
+	var printed bytes.Buffer
+	ds.Print(&printed)
+	if printed.String() != "a.txt:1: warning: warn [w]\na.txt:2:4: error: err [e]\n" {
+		t.Errorf("Unexpected printed output %q", printed.String())
+	}
+
+	var out bytes.Buffer
+	if err := ds.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var decoded []Diagnostic
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Couldn't decode JSON %s: %v", out.String(), err)
+	}
+	if len(decoded) != 2 || decoded[1] != ds[1] {
+		t.Errorf("JSON round trip mismatch: %+v", decoded)
+	}
+}
+
+func TestDiagnosticsWriteJSONEmpty(t *testing.T) {
+	var out bytes.Buffer
+	if err := Diagnostics(nil).WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	if out.String() != "[]\n" {
+		t.Errorf("Expected empty JSON array, got %q", out.String())
+	}
+}
@@ -165,3 +165,41 @@ func FindNextValidString(data []byte, startPos, endPos int, catchAll bool) (int,
 
 	return -1, endPos, false
 }
+
+// GarbagePrefixLen returns how many leading bytes of a string come before its
+// first acceptable start character (see IsAcceptableStringStart). Such a prefix
+// is probably non-string data stored next to the string, and must be kept.
+// A string without any acceptable start character has no prefix.
+func GarbagePrefixLen(data []byte) int {
+	for i, b := range data {
+		if IsAcceptableStringStart(b) {
+			return i
+		}
+	}
+	return 0
+}
+
+// PascalStrings reports whether the text at data[text:end] is a run of Pascal
+// strings, as Borland's compilers also store: a length byte, then that many
+// bytes of text, with no terminator. The first length byte is the one before
+// text, and must be at least MinStringLength; the run must end exactly at end,
+// usually a NUL. A byte before text can match the text's length by chance, so
+// the run must hold at least two strings, unless single says there's other
+// evidence, e.g. a reference to the length byte. It returns the offsets of the
+// length bytes, or nil if the text isn't such a run.
+func PascalStrings(data []byte, text, end int, single bool) []int {
+	if text < 1 || int(data[text-1]) < MinStringLength {
+		return nil
+	}
+	var starts []int
+	for at := text - 1; at != end; at += 1 + int(data[at]) {
+		if at > end || data[at] == 0 {
+			return nil
+		}
+		starts = append(starts, at)
+	}
+	if len(starts) < 2 && !single {
+		return nil
+	}
+	return starts
+}
@@ -2,6 +2,7 @@ package shared
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 )
 
@@ -640,3 +641,33 @@ func TestFindNextValidString(t *testing.T) {
 		})
 	}
 }
+
+func TestPascalStrings(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		text   int
+		single bool
+		want   []int
+	}{
+		{"single, before a NUL", "\x08New Game\x00", 1, true, []int{0}},
+		{"single, without evidence", "\x08New Game\x00", 1, false, nil},
+		{"run", "\x08New Game\x04Quit\x00", 1, false, []int{0, 9}},
+		{"garbage before the length", "\x01\x02\x08New Game\x00", 3, true, []int{2}},
+		{"garbage that matches the length", "\x01\x02\x08New Game\x00", 3, false, nil},
+		{"length doesn't match", "\x07New Game\x00", 1, true, nil},
+		{"run overshoots", "\x08New Game\x09Quit\x00", 1, false, nil},
+		{"too short", "\x02Ok\x00", 1, true, nil},
+		{"no length byte", "New Game\x00", 0, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(tt.data)
+			got := PascalStrings(data, tt.text, len(data)-1, tt.single)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}